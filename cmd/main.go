@@ -7,6 +7,7 @@ import (
 	"github.com/samber/do-template-api/pkg/config"
 	"github.com/samber/do-template-api/pkg/http"
 	"github.com/samber/do-template-api/pkg/repositories"
+	"github.com/samber/do-template-api/pkg/webhooks"
 	"github.com/samber/do/v2"
 )
 
@@ -17,6 +18,7 @@ func main() {
 		pkg.BasePackage,
 		repositories.Package,
 		http.Package,
+		webhooks.Package,
 	)
 
 	// Get services from dependency injection container
@@ -35,5 +37,9 @@ func main() {
 		appLogger.Fatal().Err(err).Msg("Failed to execute API")
 	}
 
-	_, _ = injector.ShutdownOnSignals()
+	// Gracefully shutdown every invoked service once the command returns
+	// Commands such as migrate return on their own, so the injector cannot wait for a signal to shut down
+	if report := injector.Shutdown(); !report.Succeed {
+		appLogger.Error().Err(report).Msg("Failed to shutdown services")
+	}
 }
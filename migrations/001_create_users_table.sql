@@ -21,3 +21,6 @@ COMMENT ON COLUMN users.name IS 'Full name of the user';
 COMMENT ON COLUMN users.email IS 'Email address (unique)';
 COMMENT ON COLUMN users.created_at IS 'Account creation timestamp';
 COMMENT ON COLUMN users.updated_at IS 'Last update timestamp';
+
+-- +migrate Down
+DROP TABLE IF EXISTS users;
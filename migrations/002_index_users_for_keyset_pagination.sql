@@ -0,0 +1,13 @@
+-- Index users for keyset pagination
+-- Pages are ordered by (created_at, id), so that rows inserted between two pages are neither skipped nor duplicated
+UPDATE users SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+
+-- Replace the created_at index by one matching the pagination order
+DROP INDEX IF EXISTS idx_users_created_at;
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at DESC, id DESC);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_users_created_at_id;
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL;
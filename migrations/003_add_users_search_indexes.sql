@@ -0,0 +1,15 @@
+-- Add search indexes on users
+-- Trigram indexes serve the `q=` search, name prefix and email domain filters, which all use ILIKE
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING GIN (email gin_trgm_ops);
+
+-- Users can be sorted by update time, which must be set for keyset pagination
+UPDATE users SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE users ALTER COLUMN updated_at SET NOT NULL;
+
+-- +migrate Down
+ALTER TABLE users ALTER COLUMN updated_at DROP NOT NULL;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
@@ -0,0 +1,8 @@
+-- Add a version to users
+-- Every write increments it, so that clients can detect concurrent modifications through ETags
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN users.version IS 'Optimistic concurrency version, incremented on every update';
+
+-- +migrate Down
+ALTER TABLE users DROP COLUMN IF EXISTS version;
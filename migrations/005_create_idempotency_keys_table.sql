@@ -0,0 +1,21 @@
+-- Create idempotency keys table
+-- Responses of mutating requests are stored by Idempotency-Key, so that client retries replay them instead of running twice
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    fingerprint VARCHAR(64) NOT NULL,
+    status_code INTEGER,
+    response_headers JSONB,
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create index on expires_at for purging expired keys
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Responses of mutating requests, by Idempotency-Key';
+COMMENT ON COLUMN idempotency_keys.fingerprint IS 'SHA-256 of the request method, path and body';
+COMMENT ON COLUMN idempotency_keys.status_code IS 'Response status, NULL while the request is in flight';
+
+-- +migrate Down
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,15 @@
+-- Soft delete users
+-- Deleted users are kept for support and audits, and their email can be reused by live users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users(email) WHERE deleted_at IS NULL;
+
+COMMENT ON COLUMN users.deleted_at IS 'Soft deletion timestamp, NULL for live users';
+
+-- +migrate Down
+-- Emails are unique again, so deleted users are dropped for good
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
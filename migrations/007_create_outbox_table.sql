@@ -0,0 +1,26 @@
+-- Create outbox table
+-- Domain events are written in the transaction of the change they describe, then published by the outbox relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    type VARCHAR(100) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create partial index on the pending events for polling, and index on delivered_at for purging
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(next_attempt_at, id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_delivered_at ON outbox(delivered_at) WHERE delivered_at IS NOT NULL;
+
+COMMENT ON TABLE outbox IS 'Domain events waiting to be published, transactional outbox pattern';
+COMMENT ON COLUMN outbox.type IS 'Event type, e.g. user.created';
+COMMENT ON COLUMN outbox.aggregate_id IS 'ID of the entity the event is about';
+COMMENT ON COLUMN outbox.next_attempt_at IS 'Earliest time of the next publication attempt, pushed back after failures';
+COMMENT ON COLUMN outbox.delivered_at IS 'Publication timestamp, NULL while pending';
+
+-- +migrate Down
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,42 @@
+-- Create webhooks tables
+-- Partners subscribe to the user events, which are POSTed to their URL and logged per delivery
+CREATE TABLE IF NOT EXISTS webhooks (
+    id UUID PRIMARY KEY,
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    secret VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY,
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    response_status INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create partial index on the pending deliveries for polling, and index on the delivery log of a webhook
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC, id DESC);
+
+COMMENT ON TABLE webhooks IS 'Webhook subscriptions to the domain events';
+COMMENT ON COLUMN webhooks.event_types IS 'Event types delivered to the webhook, all of them when empty';
+COMMENT ON COLUMN webhooks.secret IS 'Key of the HMAC-SHA256 signature of the deliveries';
+COMMENT ON TABLE webhook_deliveries IS 'Deliveries of the domain events to the webhooks, with the outcome of their last attempt';
+COMMENT ON COLUMN webhook_deliveries.status IS 'pending, delivered, or failed once every attempt failed';
+COMMENT ON COLUMN webhook_deliveries.next_attempt_at IS 'Earliest time of the next attempt, pushed back while a dispatcher holds the delivery and after failures';
+
+-- +migrate Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,34 @@
+-- Notify the user events recorded in the outbox
+-- Every replica of the API listens on the user_events channel to stream the changes to its clients
+CREATE OR REPLACE FUNCTION notify_user_event() RETURNS TRIGGER AS $$
+DECLARE
+    message TEXT := json_build_object(
+        'id', NEW.id,
+        'type', NEW.type,
+        'aggregate_id', NEW.aggregate_id,
+        'payload', NEW.payload,
+        'created_at', NEW.created_at
+    )::TEXT;
+BEGIN
+    -- Notification payloads are limited to 8000 bytes, the listeners read larger events back from the outbox
+    IF octet_length(message) >= 8000 THEN
+        message := json_build_object('id', NEW.id)::TEXT;
+    END IF;
+
+    PERFORM pg_notify('user_events', message);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Notifications are sent on commit, in commit order, so that listeners receive the events of a transaction together
+DROP TRIGGER IF EXISTS outbox_notify_user_event ON outbox;
+CREATE TRIGGER outbox_notify_user_event
+    AFTER INSERT ON outbox
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_user_event();
+
+COMMENT ON FUNCTION notify_user_event() IS 'Notifies the user events on the user_events channel, as JSON';
+
+-- +migrate Down
+DROP TRIGGER IF EXISTS outbox_notify_user_event ON outbox;
+DROP FUNCTION IF EXISTS notify_user_event();
@@ -0,0 +1,6 @@
+-- Index the webhook deliveries by event
+-- Events published again after a failure skip the webhooks which already hold a delivery of them
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries(event_id, webhook_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_webhook_deliveries_event_id;
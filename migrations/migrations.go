@@ -0,0 +1,16 @@
+// Package migrations embeds the SQL schema migrations so that they ship with the binary.
+package migrations
+
+import "embed"
+
+// FS holds every SQL migration file of this directory.
+// Files are named `<version>_<name>.sql`, where version is a positive integer.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLiteFS holds the migrations of the SQLite driver, in the sqlite directory.
+// SQLite has its own schema, following the same naming rules.
+//
+//go:embed sqlite/*.sql
+var SQLiteFS embed.FS
@@ -0,0 +1,20 @@
+-- Create the users table
+-- SQLite has no timestamp type: timestamps are stored as UTC RFC 3339 text with a fixed microsecond precision, so that they sort chronologically
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TEXT
+);
+
+-- Email addresses are only unique among live users, like in PostgreSQL
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users(email) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_updated_at_id ON users(updated_at, id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS users;
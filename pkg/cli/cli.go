@@ -1,16 +1,20 @@
 package cli
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/samber/do-template-api/pkg/config"
 	httpservice "github.com/samber/do-template-api/pkg/http"
+	"github.com/samber/do-template-api/pkg/repositories"
+	"github.com/samber/do-template-api/pkg/webhooks"
 	"github.com/samber/do/v2"
 	"github.com/spf13/cobra"
 )
@@ -36,6 +40,10 @@ func NewCLI(i do.Injector) (*CLI, error) {
 		Short:   "A template api application using samber/do dependency injection",
 		Long:    "A comprehensive template project demonstrating the github.com/samber/do dependency injection library with PostgreSQL and RabbitMQ integration",
 		Version: cli.config.App.Version,
+		// Refresh the configuration once flags are parsed, before any service reads it
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return cli.config.Reload()
+		},
 	}
 
 	// Add persistent flags using dependency injection
@@ -76,18 +84,35 @@ func (cli *CLI) newServeCommand() *cobra.Command {
 		Short: "Start the api service",
 		Long:  "Start the do-template-api service with dependency injection",
 		Run: func(cmd *cobra.Command, args []string) {
+			logger := do.MustInvoke[*zerolog.Logger](cli.injector)
+
+			// Apply pending migrations before accepting traffic, when enabled
+			// SQLite migrates when it is opened, and the memory driver has no schema
+			driver := cli.config.Database.Driver
+			if cli.config.Database.MigrateOnStart && (driver == "" || driver == repositories.DriverPostgres) {
+				migrator := do.MustInvoke[*repositories.Migrator](cli.injector)
+
+				count, err := migrator.Up(cmd.Context())
+				if err != nil {
+					logger.Fatal().Err(err).Msg("Failed to apply database migrations")
+				}
+				logger.Info().Int("count", count).Msg("Database migrations applied")
+			}
+
+			// Publish the domain events recorded in the outbox and deliver them to webhooks, only PostgreSQL records them
+			if driver == "" || driver == repositories.DriverPostgres {
+				do.MustInvoke[*repositories.OutboxRelay](cli.injector)
+				do.MustInvoke[*webhooks.Dispatcher](cli.injector)
+			}
+
 			// Get the HTTP server from the dependency injection container
 			// This demonstrates how to access services from the CLI using do
 			server := do.MustInvoke[*httpservice.HTTPServer](cli.injector)
-			logger := do.MustInvoke[zerolog.Logger](cli.injector)
-
-			// Setup graceful shutdown
-			_, cancel := context.WithCancel(context.Background())
-			defer cancel()
 
-			// Setup signal handling
+			// Setup signal handling, services are gracefully shut down by the caller once serve returns
 			sigChan := make(chan os.Signal, 1)
 			signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigChan)
 
 			// Start server in goroutine
 			go func() {
@@ -96,23 +121,151 @@ func (cli *CLI) newServeCommand() *cobra.Command {
 					logger.Fatal().Err(err).Msg("Failed to start HTTP server")
 				}
 			}()
+
+			// Block until a termination signal is received
+			<-sigChan
 		},
 	}
 }
 
 // newMigrateCommand creates the migrate command.
 func (cli *CLI) newMigrateCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "migrate",
 		Short: "Run database migrations",
 		Long:  "Run database migrations using the configured database connection",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Running database migrations...")
-			// This will be implemented to use the dependency injection container
+	}
+
+	cmd.AddCommand(
+		cli.newMigrateUpCommand(),
+		cli.newMigrateDownCommand(),
+		cli.newMigrateStatusCommand(),
+		cli.newMigrateGotoCommand(),
+		cli.newMigrateCreateCommand(),
+	)
+
+	return cmd
+}
+
+// newMigrateUpCommand creates the migrate up command.
+func (cli *CLI) newMigrateUpCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Get the migrator from the dependency injection container
+			migrator := do.MustInvoke[*repositories.Migrator](cli.injector)
+
+			count, err := migrator.Up(cmd.Context())
+			fmt.Printf("Applied %d migration(s)\n", count)
+			return err
+		},
+	}
+}
+
+// newMigrateDownCommand creates the migrate down command.
+func (cli *CLI) newMigrateDownCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "down [N]",
+		Short: "Revert the last N applied migrations (default 1)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n := 1
+			if len(args) == 1 {
+				var err error
+				if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+					return fmt.Errorf("invalid number of migrations: %q", args[0])
+				}
+			}
+
+			migrator := do.MustInvoke[*repositories.Migrator](cli.injector)
+
+			count, err := migrator.Down(cmd.Context(), n)
+			fmt.Printf("Reverted %d migration(s)\n", count)
+			return err
 		},
 	}
 }
 
+// newMigrateStatusCommand creates the migrate status command.
+func (cli *CLI) newMigrateStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of every migration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			migrator := do.MustInvoke[*repositories.Migrator](cli.injector)
+
+			statuses, err := migrator.Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+			for _, status := range statuses {
+				state, appliedAt := "pending", "-"
+				if status.Applied {
+					state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+				}
+				if status.Drifted {
+					state = "drifted"
+				}
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+			}
+
+			return w.Flush()
+		},
+	}
+}
+
+// newMigrateGotoCommand creates the migrate goto command.
+func (cli *CLI) newMigrateGotoCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "goto <version>",
+		Short: "Migrate up or down to the given version (0 reverts everything)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || version < 0 {
+				return fmt.Errorf("invalid migration version: %q", args[0])
+			}
+
+			migrator := do.MustInvoke[*repositories.Migrator](cli.injector)
+
+			count, err := migrator.Goto(cmd.Context(), version)
+			fmt.Printf("Applied or reverted %d migration(s)\n", count)
+			return err
+		},
+	}
+}
+
+// newMigrateCreateCommand creates the migrate create command.
+func (cli *CLI) newMigrateCreateCommand() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a new pair of up and down migration files",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			upPath, downPath, err := repositories.CreateMigration(dir, args[0], time.Now())
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Created %s\n", upPath)
+			fmt.Printf("Created %s\n", downPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "migrations", "Directory where migration files are written")
+
+	return cmd
+}
+
 // newHealthCommand creates the health command.
 func (cli *CLI) newHealthCommand() *cobra.Command {
 	return &cobra.Command{
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+// errorStatus maps repository errors to HTTP status codes
+// This is the single place deciding how storage failures are exposed to clients.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repositories.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, repositories.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repositories.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, repositories.ErrAborted):
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondError writes the problem matching err
+// Client errors expose the repository error detail, while server errors are logged and replaced by message.
+func respondError(c *gin.Context, logger *zerolog.Logger, err error, message string) {
+	writeProblem(c, errorProblem(c, logger, err, message))
+}
+
+// errorProblem returns the problem matching err, as written by respondError.
+func errorProblem(c *gin.Context, logger *zerolog.Logger, err error, message string) *Problem {
+	status := errorStatus(err)
+
+	if status >= http.StatusInternalServerError {
+		logger.Error().Err(err).Str("request_id", requestID(c)).Msg(message)
+		return newProblem(c, status, message)
+	}
+
+	return newProblem(c, status, repositories.ErrorDetail(err))
+}
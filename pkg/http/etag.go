@@ -0,0 +1,76 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag returns the strong entity tag of a resource version.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// entityTags splits an If-Match or If-None-Match header into its entity tags.
+func entityTags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// notModified reports whether the If-None-Match header of the request matches version (RFC 9110)
+// The comparison is weak, so W/ prefixed tags match as well.
+func notModified(c *gin.Context, version int64) bool {
+	current := etag(version)
+	for _, tag := range entityTags(c.GetHeader("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersions returns the versions accepted by the If-Match header of the request, nil meaning any version
+// Like every entity tag list of RFC 9110, the precondition passes when any of the tags matches.
+// It writes a 428 response when the header is missing but required, a 412 response when it cannot match any version,
+// a 400 response when it mixes * with entity tags, and returns false in those cases.
+func ifMatchVersions(c *gin.Context, required bool) ([]int64, bool) {
+	tags := entityTags(c.GetHeader("If-Match"))
+
+	switch {
+	case len(tags) == 0:
+		if required {
+			respondProblem(c, http.StatusPreconditionRequired, "This request requires an If-Match header")
+			return nil, false
+		}
+		return nil, true
+	case slices.Contains(tags, "*"):
+		if len(tags) > 1 {
+			respondProblem(c, http.StatusBadRequest, "If-Match must hold either entity tags or *")
+			return nil, false
+		}
+		return nil, true
+	}
+
+	// Weak tags never match with the strong comparison of If-Match
+	var versions []int64
+	for _, tag := range tags {
+		version, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+		if err == nil && version > 0 && strings.HasPrefix(tag, `"`) {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		respondProblem(c, http.StatusPreconditionFailed, "user has been modified since it was read")
+		return nil, false
+	}
+
+	return versions, true
+}
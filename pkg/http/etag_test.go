@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotModified(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	testCases := map[string]bool{
+		"":              false,
+		`"3"`:           true,
+		`"2"`:           false,
+		`W/"3"`:         true,
+		`"1", "3"`:      true,
+		"*":             true,
+		`"3-something"`: false,
+	}
+
+	for header, want := range testCases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		c.Request.Header.Set("If-None-Match", header)
+
+		if got := notModified(c, 3); got != want {
+			t.Errorf("notModified(%q) = %t, want %t", header, got, want)
+		}
+	}
+}
+
+func TestIfMatchVersions(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	testCases := map[string]struct {
+		header   string
+		required bool
+		versions []int64
+		status   int
+	}{
+		"missing":          {header: ""},
+		"missing required": {header: "", required: true, status: http.StatusPreconditionRequired},
+		"wildcard":         {header: "*", required: true},
+		"strong":           {header: `"7"`, versions: []int64{7}},
+		"weak":             {header: `W/"7"`, status: http.StatusPreconditionFailed},
+		"foreign":          {header: `"abc"`, status: http.StatusPreconditionFailed},
+		"several":          {header: `"7", "8"`, versions: []int64{7, 8}},
+		"several and weak": {header: `W/"6", "7"`, versions: []int64{7}},
+		"wildcard and tag": {header: `*, "7"`, status: http.StatusBadRequest},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodPut, "/users/1", nil)
+			if tc.header != "" {
+				c.Request.Header.Set("If-Match", tc.header)
+			}
+
+			versions, ok := ifMatchVersions(c, tc.required)
+			if tc.status != 0 {
+				if ok || rec.Code != tc.status {
+					t.Errorf("ifMatchVersions() = %t with status %d, want false with status %d", ok, rec.Code, tc.status)
+				}
+				return
+			}
+
+			if !ok || !slices.Equal(versions, tc.versions) {
+				t.Errorf("ifMatchVersions() = %v, %t, want %v, true", versions, ok, tc.versions)
+			}
+		})
+	}
+}
@@ -1,30 +1,82 @@
 package http
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/repositories"
 	"github.com/samber/do/v2"
 )
 
+// readinessTimeout bounds the duration of the readiness checks.
+const readinessTimeout = 5 * time.Second
+
 // HealthHandler handles health check requests
 // This demonstrates how to implement health check endpoint.
 type HealthHandler struct {
-	logger zerolog.Logger `do:""`
+	logger   *zerolog.Logger `do:""`
+	injector do.Injector
 }
 
 // NewHealthHandler creates a new HealthHandler with dependency injection
 // This demonstrates how to initialize health handlers with dependencies.
 func NewHealthHandler(injector do.Injector) (*HealthHandler, error) {
-	return do.MustInvokeStruct[*HealthHandler](injector), nil
+	handler := do.MustInvokeStruct[*HealthHandler](injector)
+	handler.injector = injector
+	return handler, nil
 }
 
 // healthCheck handles health check requests.
 func (h *HealthHandler) healthCheck(c *gin.Context) {
 	h.logger.Info().Msg("health check")
-	c.JSON(http.StatusOK, HealthResponse{
+
+	response := HealthResponse{
 		Status:  "healthy",
 		Service: "do-template-api",
-	})
+	}
+
+	// Report the hits and misses of the user cache, once the repository has been created
+	if repo, err := do.Invoke[repositories.UserRepository](h.injector); err == nil {
+		if reporter, ok := repo.(repositories.CacheStatsReporter); ok {
+			stats := reporter.CacheStats()
+			response.Cache = &CacheStatsResponse{
+				Hits:      stats.Hits,
+				Misses:    stats.Misses,
+				Evictions: stats.Evictions,
+				Size:      stats.Size,
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// readinessCheck handles readiness check requests
+// This demonstrates how to rely on the health checks of every invoked service, such as the database.
+func (h *HealthHandler) readinessCheck(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{}
+	status := http.StatusOK
+	for service, err := range h.injector.HealthCheckWithContext(ctx) {
+		if err != nil {
+			checks[service] = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	response := ReadinessResponse{
+		Status:  "ready",
+		Service: "do-template-api",
+		Checks:  checks,
+	}
+	if status != http.StatusOK {
+		response.Status = "not ready"
+	}
+
+	c.JSON(status, response)
 }
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
+	"github.com/samber/do/v2"
+)
+
+func TestReadinessCheckSQLite(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	logger := zerolog.Nop()
+	injector := do.New(repositories.Package)
+	do.ProvideValue(injector, &config.Config{Database: config.DatabaseConfig{Driver: repositories.DriverSQLite, Path: ":memory:"}})
+	do.ProvideValue(injector, &logger)
+	t.Cleanup(func() { injector.Shutdown() })
+
+	// Invoking the repository opens the database, whose health is then checked
+	do.MustInvoke[repositories.UserRepository](injector)
+
+	handler := &HealthHandler{logger: &logger, injector: injector}
+	engine := gin.New()
+	engine.GET("/ready", handler.readinessCheck)
+
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		return rec.Code
+	}
+
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("readiness = %d, want 200 while the database is open", code)
+	}
+
+	if err := do.MustInvoke[*repositories.SQLiteDatabase](injector).Shutdown(); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("readiness = %d, want 503 once the database is closed", code)
+	}
+}
@@ -0,0 +1,212 @@
+package http
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	detranslations "github.com/go-playground/validator/v10/translations/de"
+	estranslations "github.com/go-playground/validator/v10/translations/es"
+	frtranslations "github.com/go-playground/validator/v10/translations/fr"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+	"golang.org/x/text/language"
+)
+
+// localizerKey is the gin context key of the localizer negotiated for the request.
+const localizerKey = "localizer"
+
+// catalogFS holds the message catalogs, one `<locale>.json` file per language.
+// Catalogs map English source messages to their translation, English itself needs none.
+//
+//go:embed locales/*.json
+var catalogFS embed.FS
+
+// supportedLocale describes a language the API is translated into.
+// English validation messages are the source strings, so it registers no validator translations.
+type supportedLocale struct {
+	translator        locales.Translator
+	registerValidator func(v *validator.Validate, trans ut.Translator) error
+}
+
+// supportedLocales lists the languages the API is translated into.
+var supportedLocales = map[string]supportedLocale{
+	"en": {en.New(), nil},
+	"fr": {fr.New(), frtranslations.RegisterDefaultTranslations},
+	"es": {es.New(), estranslations.RegisterDefaultTranslations},
+	"de": {de.New(), detranslations.RegisterDefaultTranslations},
+}
+
+// Translator renders API messages in the language negotiated from the Accept-Language header
+// This service demonstrates how to share stateful helpers between handlers through dependency injection.
+type Translator struct {
+	universal     *ut.UniversalTranslator
+	catalogs      map[string]map[string]string
+	matcher       language.Matcher
+	defaultLocale string
+}
+
+// NewTranslator creates a new Translator loaded with the embedded catalogs
+// It also registers the validation messages of every supported language.
+func NewTranslator(injector do.Injector) (*Translator, error) {
+	cfg := do.MustInvoke[*config.Config](injector)
+
+	defaultLocale := cfg.App.DefaultLocale
+	if defaultLocale == "" {
+		defaultLocale = "en"
+	}
+
+	fallback, ok := supportedLocales[defaultLocale]
+	if !ok {
+		return nil, fmt.Errorf("unsupported default locale: %q", defaultLocale)
+	}
+
+	// The default locale comes first, so that the matcher falls back to it
+	tags := []language.Tag{language.Make(defaultLocale)}
+	translators := make([]locales.Translator, 0, len(supportedLocales))
+	for locale, supported := range supportedLocales {
+		translators = append(translators, supported.translator)
+		if locale != defaultLocale {
+			tags = append(tags, language.Make(locale))
+		}
+	}
+
+	t := &Translator{
+		universal:     ut.New(fallback.translator, translators...),
+		catalogs:      map[string]map[string]string{},
+		matcher:       language.NewMatcher(tags),
+		defaultLocale: defaultLocale,
+	}
+
+	if err := t.loadCatalogs(); err != nil {
+		return nil, err
+	}
+
+	if err := t.registerValidationMessages(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// loadCatalogs reads the embedded message catalogs.
+func (t *Translator) loadCatalogs() error {
+	for locale := range supportedLocales {
+		content, err := catalogFS.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			// English messages are the source strings, a catalog is optional
+			continue
+		}
+
+		catalog := map[string]string{}
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			return fmt.Errorf("failed to parse %s message catalog: %w", locale, err)
+		}
+		t.catalogs[locale] = catalog
+	}
+
+	return nil
+}
+
+// registerValidationMessages registers the validator messages of every supported language.
+func (t *Translator) registerValidationMessages() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+
+	for locale, supported := range supportedLocales {
+		if supported.registerValidator == nil {
+			continue
+		}
+
+		trans, _ := t.universal.GetTranslator(locale)
+		if err := supported.registerValidator(v, trans); err != nil {
+			return fmt.Errorf("failed to register %s validation messages: %w", locale, err)
+		}
+	}
+
+	return nil
+}
+
+// negotiate returns the supported locale best matching an Accept-Language header.
+func (t *Translator) negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return t.defaultLocale
+	}
+
+	tag, _, _ := t.matcher.Match(tags...)
+	base, _ := tag.Base()
+	if _, ok := supportedLocales[base.String()]; !ok {
+		return t.defaultLocale
+	}
+
+	return base.String()
+}
+
+// middleware negotiates the language of the request and stores its localizer in the gin context.
+func (t *Translator) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := t.negotiate(c.GetHeader("Accept-Language"))
+		trans, _ := t.universal.GetTranslator(locale)
+
+		c.Set(localizerKey, &localizer{
+			locale:  locale,
+			catalog: t.catalogs[locale],
+			trans:   trans,
+		})
+		c.Header("Content-Language", locale)
+		c.Next()
+	}
+}
+
+// localizer translates the messages of a single request.
+type localizer struct {
+	locale  string
+	catalog map[string]string
+	trans   ut.Translator
+}
+
+// translate returns message in the language of the request, with `{N}` placeholders replaced by params
+// Messages missing from the catalog, or requests without localizer, are rendered in English.
+func translate(c *gin.Context, message string, params ...string) string {
+	if l, ok := c.Value(localizerKey).(*localizer); ok {
+		if translated, ok := l.catalog[message]; ok {
+			message = translated
+		}
+	}
+
+	if len(params) == 0 {
+		return message
+	}
+
+	replacements := make([]string, 0, 2*len(params))
+	for i, param := range params {
+		replacements = append(replacements, "{"+strconv.Itoa(i)+"}", param)
+	}
+
+	return strings.NewReplacer(replacements...).Replace(message)
+}
+
+// translateFieldError returns the validation message of fe in the language of the request.
+func translateFieldError(c *gin.Context, fe validator.FieldError) string {
+	if l, ok := c.Value(localizerKey).(*localizer); ok && supportedLocales[l.locale].registerValidator != nil {
+		if message := fe.Translate(l.trans); message != fe.Error() {
+			return message
+		}
+	}
+
+	return validationMessage(fe)
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+func newTestTranslator(t *testing.T, defaultLocale string) *Translator {
+	t.Helper()
+
+	injector := do.New()
+	do.ProvideValue(injector, &config.Config{App: config.AppConfig{DefaultLocale: defaultLocale}})
+
+	translator, err := NewTranslator(injector)
+	if err != nil {
+		t.Fatalf("NewTranslator() error = %v", err)
+	}
+	return translator
+}
+
+func TestTranslatorNegotiate(t *testing.T) {
+	t.Parallel()
+
+	translator := newTestTranslator(t, "en")
+
+	testCases := map[string]string{
+		"":                     "en",
+		"fr":                   "fr",
+		"fr-CA,fr;q=0.9":       "fr",
+		"es-MX":                "es",
+		"it,de;q=0.8,en;q=0.5": "de",
+		"ja":                   "en",
+		"not a language;;":     "en",
+	}
+
+	for header, want := range testCases {
+		if got := translator.negotiate(header); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+
+	if got := newTestTranslator(t, "de").negotiate("ja"); got != "de" {
+		t.Errorf("negotiate() with default locale de = %q, want de", got)
+	}
+}
+
+func TestNewTranslatorUnsupportedLocale(t *testing.T) {
+	t.Parallel()
+
+	injector := do.New()
+	do.ProvideValue(injector, &config.Config{App: config.AppConfig{DefaultLocale: "xx"}})
+
+	if _, err := NewTranslator(injector); err == nil {
+		t.Fatal("NewTranslator() error = nil, want an unsupported locale error")
+	}
+}
+
+func TestLocalizedProblems(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	setupValidator()
+	translator := newTestTranslator(t, "en")
+
+	engine := gin.New()
+	engine.Use(translator.middleware())
+	engine.POST("/users", func(c *gin.Context) {
+		var req CreateUserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	engine.NoRoute(func(c *gin.Context) {
+		respondProblem(c, http.StatusNotFound, "No route matches {0}", c.Request.URL.Path)
+	})
+
+	testCases := map[string]struct {
+		method, path, body, language string
+		title, detail, message       string
+	}{
+		"english": {
+			method: http.MethodPost, path: "/users", body: `{"email":"john@example.com"}`, language: "en-US",
+			title: "Bad Request", detail: "The request body failed validation", message: "name is required",
+		},
+		"french": {
+			method: http.MethodPost, path: "/users", body: `{"email":"john@example.com"}`, language: "fr-FR,fr;q=0.9",
+			title: "Requête invalide", detail: "Le corps de la requête est invalide", message: "name est un champ obligatoire",
+		},
+		"german params": {
+			method: http.MethodGet, path: "/nowhere", language: "de",
+			title: "Nicht gefunden", detail: "Keine Route entspricht /nowhere",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Accept-Language", tc.language)
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("invalid problem body %q: %v", rec.Body.String(), err)
+			}
+
+			if problem.Title != tc.title || problem.Detail != tc.detail {
+				t.Errorf("problem = %q / %q, want %q / %q", problem.Title, problem.Detail, tc.title, tc.detail)
+			}
+			if tc.message != "" && (len(problem.Errors) != 1 || problem.Errors[0].Message != tc.message) {
+				t.Errorf("errors = %+v, want a single %q message", problem.Errors, tc.message)
+			}
+		})
+	}
+}
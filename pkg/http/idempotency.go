@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+const (
+	// idempotencyKeyHeader carries the key identifying a request across client retries.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotentReplayedHeader marks responses replayed from a previous request.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength bounds the length of idempotency keys accepted from clients.
+	maxIdempotencyKeyLength = 255
+
+	// maxIdempotentRequestSize bounds the bodies read in memory to fingerprint requests, well above the largest batch.
+	maxIdempotentRequestSize = 1 << 20
+)
+
+// replayedHeaders lists the response headers stored with idempotent responses.
+var replayedHeaders = []string{"Content-Type", "Content-Language", "Location", "ETag", "Link"}
+
+// bufferedResponseWriter keeps a copy of the response body written to the client.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write implements io.Writer.
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString implements io.StringWriter.
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyMiddleware makes mutating requests carrying an Idempotency-Key safe to retry
+// The first response is stored and replayed to retries, concurrent duplicates get a 409,
+// and reusing a key for another request gets a 422. Server errors are not stored, so that they can be retried.
+func idempotencyMiddleware(store repositories.IdempotencyStore, logger *zerolog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondProblem(c, http.StatusBadRequest, "Idempotency-Key must be at most {0} characters long", "255")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentRequestSize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondProblem(c, http.StatusRequestEntityTooLarge, "The request body must be at most {0} bytes", strconv.FormatInt(tooLarge.Limit, 10))
+			return
+		}
+		if err != nil {
+			respondBindError(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		reservedAt, record, err := store.Begin(c.Request.Context(), key, fingerprint)
+		if err != nil {
+			respondError(c, logger, err, "Failed to process idempotent request")
+			return
+		}
+
+		if record != nil {
+			switch {
+			case record.Fingerprint != fingerprint:
+				respondProblem(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for another request")
+			case !record.Completed():
+				respondProblem(c, http.StatusConflict, "A request with this Idempotency-Key is already in progress")
+			default:
+				replayResponse(c, record)
+			}
+			return
+		}
+
+		// The response is already sent, storing it must not depend on the client staying connected
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		// A panicking handler must not hold the key until it is considered abandoned
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if err := store.Release(ctx, key, reservedAt); err != nil {
+					logger.Error().Err(err).Str("request_id", requestID(c)).Msg("Failed to release idempotency key")
+				}
+				panic(recovered)
+			}
+		}()
+
+		writer := &bufferedResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if status := writer.Status(); status >= http.StatusInternalServerError {
+			if err := store.Release(ctx, key, reservedAt); err != nil {
+				logger.Error().Err(err).Str("request_id", requestID(c)).Msg("Failed to release idempotency key")
+			}
+			return
+		}
+
+		header := map[string][]string{}
+		for _, name := range replayedHeaders {
+			if values := writer.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+
+		if err := store.Complete(ctx, key, reservedAt, writer.Status(), header, writer.body.Bytes()); err != nil {
+			logger.Error().Err(err).Str("request_id", requestID(c)).Msg("Failed to store idempotent response")
+		}
+	}
+}
+
+// requestFingerprint identifies a request by its method, URI and body.
+func requestFingerprint(method, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + uri + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replayResponse writes the response stored for an idempotent request
+// The stored headers replace those already set by the middlewares, such as Content-Language.
+func replayResponse(c *gin.Context, record *repositories.IdempotencyRecord) {
+	header := c.Writer.Header()
+	for name, values := range record.Header {
+		header.Del(name)
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	c.Header(idempotentReplayedHeader, "true")
+
+	c.Status(record.StatusCode)
+	_, _ = c.Writer.Write(record.Body)
+	c.Abort()
+}
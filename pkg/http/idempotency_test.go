@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+// fakeIdempotencyStore is an in-memory repositories.IdempotencyStore.
+type fakeIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*repositories.IdempotencyRecord
+}
+
+func (s *fakeIdempotencyStore) Begin(_ context.Context, key, fingerprint string) (time.Time, *repositories.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		copied := *record
+		return time.Time{}, &copied, nil
+	}
+	s.records[key] = &repositories.IdempotencyRecord{Key: key, Fingerprint: fingerprint}
+	return time.Now(), nil, nil
+}
+
+func (s *fakeIdempotencyStore) Complete(_ context.Context, key string, _ time.Time, statusCode int, header map[string][]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.records[key]
+	record.StatusCode, record.Header, record.Body = statusCode, header, body
+	return nil
+}
+
+func (s *fakeIdempotencyStore) Release(_ context.Context, key string, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	store := &fakeIdempotencyStore{records: map[string]*repositories.IdempotencyRecord{}}
+
+	calls := 0
+	engine := gin.New()
+	engine.Use(idempotencyMiddleware(store, &logger))
+	engine.POST("/users", func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/users/1")
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+	engine.POST("/failing", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusInternalServerError)
+	})
+
+	send := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(idempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("/users", "key-1", `{"name":"John"}`)
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("first request = %d after %d calls", first.Code, calls)
+	}
+
+	replayed := send("/users", "key-1", `{"name":"John"}`)
+	if replayed.Code != http.StatusCreated || calls != 1 {
+		t.Errorf("retry = %d after %d calls, want a replay", replayed.Code, calls)
+	}
+	if replayed.Body.String() != first.Body.String() || replayed.Header().Get("Location") != "/users/1" {
+		t.Errorf("replayed %q with headers %v, want %q", replayed.Body.String(), replayed.Header(), first.Body.String())
+	}
+	if replayed.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("replayed response is not marked as such")
+	}
+
+	if rec := send("/users", "key-1", `{"name":"Jane"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key = %d, want 422", rec.Code)
+	}
+
+	store.records["key-2"] = &repositories.IdempotencyRecord{Key: "key-2", Fingerprint: requestFingerprint(http.MethodPost, "/users", nil)}
+	if rec := send("/users", "key-2", ""); rec.Code != http.StatusConflict {
+		t.Errorf("in-flight duplicate = %d, want 409", rec.Code)
+	}
+
+	// Bodies are read in memory to fingerprint requests, so their size is bounded
+	if rec := send("/users", "key-4", strings.Repeat(" ", maxIdempotentRequestSize+1)); rec.Code != http.StatusRequestEntityTooLarge || calls != 1 {
+		t.Errorf("oversized request = %d after %d calls, want 413", rec.Code, calls)
+	}
+
+	// Server errors are not stored, so that they can be retried
+	send("/failing", "key-3", "")
+	send("/failing", "key-3", "")
+	if calls != 3 {
+		t.Errorf("failing request ran %d times in total, want retries to run again", calls)
+	}
+
+	// Requests without key are not affected
+	send("/users", "", "")
+	send("/users", "", "")
+	if calls != 5 {
+		t.Errorf("requests without key ran %d times in total, want 5", calls)
+	}
+}
+
+func TestIdempotencyMiddlewareReplaysHeaders(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	store := &fakeIdempotencyStore{records: map[string]*repositories.IdempotencyRecord{}}
+
+	engine := gin.New()
+	// Like the locale middleware, sets a header which is also stored with the response
+	engine.Use(func(c *gin.Context) { c.Header("Content-Language", "fr") })
+	engine.Use(idempotencyMiddleware(store, &logger))
+	engine.POST("/users", func(c *gin.Context) {
+		c.Header("Location", "/users/1")
+		c.Writer.Header().Add("Link", `</users?page=2>; rel="next"`)
+		c.Writer.Header().Add("Link", `</users?page=1>; rel="prev"`)
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", nil)
+		req.Header.Set(idempotencyKeyHeader, "key")
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	send()
+	replayed := send()
+
+	want := http.Header{
+		"Content-Type":           {"application/json; charset=utf-8"},
+		"Content-Language":       {"fr"},
+		"Location":               {"/users/1"},
+		"Link":                   {`</users?page=2>; rel="next"`, `</users?page=1>; rel="prev"`},
+		idempotentReplayedHeader: {"true"},
+	}
+	if !reflect.DeepEqual(replayed.Header(), want) {
+		t.Errorf("replayed headers = %v, want %v", replayed.Header(), want)
+	}
+}
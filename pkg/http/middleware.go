@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// requestIDHeader carries the request ID, from clients or proxies and back to clients.
+	requestIDHeader = "X-Request-ID"
+
+	// requestIDKey is the gin context key of the request ID.
+	requestIDKey = "request_id"
+
+	// maxRequestIDLength bounds the length of request IDs accepted from clients.
+	maxRequestIDLength = 128
+)
+
+// requestIDMiddleware reuses the request ID sent by the client, or generates one,
+// and echoes it in the response headers.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestID returns the ID of the current request.
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// recoveryMiddleware turns panics into 500 problems.
+func recoveryMiddleware(logger *zerolog.Logger) gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		logger.Error().Interface("panic", recovered).Str("request_id", requestID(c)).Msg("Recovered from panic")
+		respondProblem(c, http.StatusInternalServerError, "Internal server error")
+	})
+}
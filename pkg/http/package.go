@@ -10,4 +10,7 @@ var Package = do.Package(
 	do.Lazy(NewHTTPServer),
 	do.Lazy(NewUserHandler),
 	do.Lazy(NewHealthHandler),
+	do.Lazy(NewWebhookHandler),
+	do.Lazy(NewUserEventHandler),
+	do.Lazy(NewTranslator),
 )
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"errors"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types of the patch documents accepted by PATCH endpoints.
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// acceptPatch lists the supported patch formats, as advertised by the Accept-Patch header (RFC 5789).
+const acceptPatch = mergePatchContentType + ", " + jsonPatchContentType
+
+var (
+	// errUnsupportedPatch is returned for patch documents of an unknown media type.
+	errUnsupportedPatch = errors.New("unsupported patch media type")
+	// errMalformedPatch is returned for patch documents that cannot be parsed.
+	errMalformedPatch = errors.New("malformed patch document")
+	// errPatchNotApplicable is returned when a patch cannot be applied to the current document, e.g. a failing `test` operation.
+	errPatchNotApplicable = errors.New("patch cannot be applied")
+)
+
+// applyPatch applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) document to doc
+// The format is selected by the media type of the request.
+func applyPatch(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errUnsupportedPatch
+	}
+
+	switch mediaType {
+	case mergePatchContentType:
+		patched, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, errMalformedPatch
+		}
+		return patched, nil
+	case jsonPatchContentType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, errMalformedPatch
+		}
+
+		patched, err := operations.Apply(doc)
+		if err != nil {
+			return nil, errors.Join(errPatchNotApplicable, err)
+		}
+		return patched, nil
+	default:
+		return nil, errUnsupportedPatch
+	}
+}
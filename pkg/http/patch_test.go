@@ -0,0 +1,108 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	t.Parallel()
+
+	doc := []byte(`{"id":"0190f0a4-5b1e-7c4e-9d6a-2f1c3e4b5a69","name":"John","email":"john@example.com"}`)
+
+	testCases := map[string]struct {
+		contentType string
+		patch       string
+		name, email string
+		err         error
+	}{
+		"merge patch": {
+			contentType: "application/merge-patch+json",
+			patch:       `{"name":"Jane"}`,
+			name:        "Jane", email: "john@example.com",
+		},
+		"merge patch with charset": {
+			contentType: "application/merge-patch+json; charset=utf-8",
+			patch:       `{"email":"jane@example.com"}`,
+			name:        "John", email: "jane@example.com",
+		},
+		"json patch": {
+			contentType: "application/json-patch+json",
+			patch:       `[{"op":"test","path":"/name","value":"John"},{"op":"replace","path":"/name","value":"Jane"}]`,
+			name:        "Jane", email: "john@example.com",
+		},
+		"failing test operation": {
+			contentType: "application/json-patch+json",
+			patch:       `[{"op":"test","path":"/name","value":"Jack"}]`,
+			err:         errPatchNotApplicable,
+		},
+		"malformed json patch": {
+			contentType: "application/json-patch+json",
+			patch:       `{"op":"replace"}`,
+			err:         errMalformedPatch,
+		},
+		"malformed merge patch": {
+			contentType: "application/merge-patch+json",
+			patch:       `{"name":`,
+			err:         errMalformedPatch,
+		},
+		"plain json": {
+			contentType: "application/json",
+			patch:       `{"name":"Jane"}`,
+			err:         errUnsupportedPatch,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			patched, err := applyPatch(tc.contentType, doc, []byte(tc.patch))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("applyPatch() error = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPatch() error = %v", err)
+			}
+
+			var req UpdateUserRequest
+			if err := json.Unmarshal(patched, &req); err != nil {
+				t.Fatalf("invalid patched document %s: %v", patched, err)
+			}
+			if req.Name != tc.name || req.Email != tc.email {
+				t.Errorf("patched = %+v, want %s <%s>", req, tc.name, tc.email)
+			}
+			if !onlyFieldsChanged(doc, patched, "name", "email") {
+				t.Errorf("onlyFieldsChanged() = false for %s", patched)
+			}
+		})
+	}
+}
+
+func TestOnlyFieldsChanged(t *testing.T) {
+	t.Parallel()
+
+	before := []byte(`{"id":"1","name":"John"}`)
+
+	testCases := map[string]struct {
+		after string
+		want  bool
+	}{
+		"allowed field":      {after: `{"id":"1","name":"Jane"}`, want: true},
+		"read-only field":    {after: `{"id":"2","name":"John"}`, want: false},
+		"removed field":      {after: `{"name":"John"}`, want: false},
+		"added field":        {after: `{"id":"1","name":"John","admin":true}`, want: false},
+		"not an object":      {after: `"John"`, want: false},
+		"allowed field gone": {after: `{"id":"1"}`, want: true},
+	}
+
+	for name, tc := range testCases {
+		if got := onlyFieldsChanged(before, []byte(tc.after), "name", "email"); got != tc.want {
+			t.Errorf("%s: onlyFieldsChanged() = %t, want %t", name, got, tc.want)
+		}
+	}
+}
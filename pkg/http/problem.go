@@ -0,0 +1,61 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the media type of RFC 7807 error responses.
+const problemContentType = "application/problem+json"
+
+// Problem types, as defined by RFC 7807. Errors without a more specific type use about:blank.
+const (
+	problemTypeDefault    = "about:blank"
+	problemTypeValidation = "urn:problem-type:validation-error"
+)
+
+// Problem represents an RFC 7807 problem details response
+// This demonstrates how to render errors in a standard, machine-readable format.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes a field of the request body failing a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// newProblem creates a problem for the current request, in the language negotiated for it
+// The `{N}` placeholders of detail are replaced by params after translation.
+func newProblem(c *gin.Context, status int, detail string, params ...string) *Problem {
+	return &Problem{
+		Type:      problemTypeDefault,
+		Title:     translate(c, http.StatusText(status)),
+		Status:    status,
+		Detail:    translate(c, detail, params...),
+		Instance:  c.Request.URL.Path,
+		RequestID: requestID(c),
+	}
+}
+
+// writeProblem writes problem as an application/problem+json response and aborts the request.
+func writeProblem(c *gin.Context, problem *Problem) {
+	// Setting the content type first prevents gin from overriding it
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// respondProblem writes a problem with the given status and detail.
+func respondProblem(c *gin.Context, status int, detail string, params ...string) {
+	writeProblem(c, newProblem(c, status, detail, params...))
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,18 +10,25 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
 	"github.com/samber/do/v2"
 )
 
 // HTTPServer represents the HTTP server service
 // This demonstrates how to create an HTTP server with dependency injection using do.
 type HTTPServer struct {
-	config        *config.Config `do:""`
-	logger        zerolog.Logger `do:""`
-	userHandler   *UserHandler   `do:""`
-	healthHandler *HealthHandler `do:""`
-	server        *http.Server
-	engine        *gin.Engine
+	config        *config.Config                `do:""`
+	logger        *zerolog.Logger               `do:""`
+	userHandler   *UserHandler                  `do:""`
+	healthHandler *HealthHandler                `do:""`
+	translator    *Translator                   `do:""`
+	idempotency   repositories.IdempotencyStore `do:""`
+	// webhookHandler is nil when the database driver does not support webhooks
+	webhookHandler *WebhookHandler
+	// userEventHandler is nil when the database driver does not stream user events
+	userEventHandler *UserEventHandler
+	server           *http.Server
+	engine           *gin.Engine
 }
 
 // NewHTTPServer creates a new HTTP server with dependency injection
@@ -28,10 +36,24 @@ type HTTPServer struct {
 func NewHTTPServer(injector do.Injector) (*HTTPServer, error) {
 	server := do.MustInvokeStruct[*HTTPServer](injector)
 
+	// Optional features are only skipped when the database driver does not provide them
+	var err error
+	if server.webhookHandler, err = optionalHandler[*WebhookHandler](injector); err != nil {
+		return nil, err
+	}
+	if server.userEventHandler, err = optionalHandler[*UserEventHandler](injector); err != nil {
+		return nil, err
+	}
+
 	// Setup Gin engine
 	server.engine = gin.New()
+	server.engine.Use(requestIDMiddleware())
+	server.engine.Use(server.translator.middleware())
 	server.engine.Use(gin.Logger())
-	server.engine.Use(gin.Recovery())
+	server.engine.Use(recoveryMiddleware(server.logger))
+
+	// Report validation errors with JSON field names
+	setupValidator()
 
 	// Setup routes
 	server.setupRoutes()
@@ -45,26 +67,73 @@ func NewHTTPServer(injector do.Injector) (*HTTPServer, error) {
 		IdleTimeout:  60 * time.Second, // Default idle timeout
 	}
 
+	// Event streams never end on their own, they are closed as soon as the server starts shutting down
+	if server.userEventHandler != nil {
+		server.server.RegisterOnShutdown(server.userEventHandler.close)
+	}
+
 	return server, nil
 }
 
+// optionalHandler invokes a handler which is nil when the database driver does not support its feature.
+func optionalHandler[T any](injector do.Injector) (T, error) {
+	handler, err := do.Invoke[T](injector)
+	if errors.Is(err, repositories.ErrUnsupportedDriver) {
+		var none T
+		return none, nil
+	}
+	return handler, err
+}
+
 // setupRoutes defines all the HTTP routes for the API
 // This demonstrates how to organize routes in a structured way.
 func (s *HTTPServer) setupRoutes() {
 	api := s.engine.Group("/api/v1")
 
+	// Mutating requests can be retried safely with an Idempotency-Key
+	api.Use(idempotencyMiddleware(s.idempotency, s.logger))
+
 	// User routes
 	users := api.Group("/users")
 	{
+		if s.userEventHandler != nil {
+			users.GET("/events", s.userEventHandler.streamUserEvents)
+		}
 		users.POST("", s.userHandler.createUser)
 		users.GET("", s.userHandler.listUsers)
 		users.GET("/:id", s.userHandler.getUser)
 		users.PUT("/:id", s.userHandler.updateUser)
+		users.PATCH("/:id", s.userHandler.patchUser)
 		users.DELETE("/:id", s.userHandler.deleteUser)
+		// Custom methods of a user, e.g. POST /api/v1/users/:id:restore
+		users.POST("/:id", s.userHandler.userAction)
 	}
 
-	// Health check endpoint
+	// Bulk operations, e.g. POST /api/v1/users:batchCreate
+	api.POST("/users:action", s.userHandler.batchUsers)
+
+	// Webhook routes, e.g. POST /api/v1/webhooks/:id/deliveries/:delivery:redeliver
+	if s.webhookHandler != nil {
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.POST("", s.webhookHandler.createWebhook)
+			webhooks.GET("", s.webhookHandler.listWebhooks)
+			webhooks.GET("/:id", s.webhookHandler.getWebhook)
+			webhooks.PUT("/:id", s.webhookHandler.updateWebhook)
+			webhooks.DELETE("/:id", s.webhookHandler.deleteWebhook)
+			webhooks.GET("/:id/deliveries", s.webhookHandler.listWebhookDeliveries)
+			webhooks.POST("/:id/deliveries/:delivery", s.webhookHandler.deliveryAction)
+		}
+	}
+
+	// Health check endpoints
 	s.engine.GET("/health", s.healthHandler.healthCheck)
+	s.engine.GET("/ready", s.healthHandler.readinessCheck)
+
+	// Unknown routes
+	s.engine.NoRoute(func(c *gin.Context) {
+		respondProblem(c, http.StatusNotFound, "No route matches {0}", c.Request.Method+" "+c.Request.URL.Path)
+	})
 }
 
 // Start starts the HTTP server
@@ -78,9 +147,9 @@ func (s *HTTPServer) Start() error {
 	return s.server.ListenAndServe()
 }
 
-// Stop gracefully shuts down the HTTP server
-// This demonstrates proper shutdown handling.
-func (s *HTTPServer) ShutdownWithContext(ctx context.Context) error {
+// Shutdown gracefully shuts down the HTTP server
+// This implements do.ShutdownerWithContextAndError, so that the injector stops the server before its dependencies.
+func (s *HTTPServer) Shutdown(ctx context.Context) error {
 	s.logger.Info().Msg("Stopping HTTP server")
 	return s.server.Shutdown(ctx)
 }
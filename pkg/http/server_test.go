@@ -0,0 +1,48 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/samber/do-template-api/pkg/repositories"
+	"github.com/samber/do/v2"
+)
+
+func TestOptionalHandler(t *testing.T) {
+	t.Parallel()
+
+	errFailed := errors.New("failed")
+
+	testCases := map[string]struct {
+		err         error
+		wantHandler bool
+		wantErr     error
+	}{
+		"provided":    {wantHandler: true},
+		"unsupported": {err: fmt.Errorf("%w: the memory database driver does not support webhooks", repositories.ErrUnsupportedDriver)},
+		"failed":      {err: errFailed, wantErr: errFailed},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			injector := do.New()
+			do.Provide(injector, func(do.Injector) (*WebhookHandler, error) {
+				if tc.err != nil {
+					return nil, tc.err
+				}
+				return &WebhookHandler{}, nil
+			})
+
+			handler, err := optionalHandler[*WebhookHandler](injector)
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Fatalf("optionalHandler() error = %v, want %v", err, tc.wantErr)
+			}
+			if (handler != nil) != tc.wantHandler {
+				t.Errorf("optionalHandler() = %v, want a handler: %v", handler, tc.wantHandler)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package http
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // CreateUserRequest represents the request body for creating a user
@@ -18,18 +21,147 @@ type UpdateUserRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
 
+// BatchCreateUsersRequest represents the request body for creating users in bulk
+// In atomic mode, either every user is created or none is.
+type BatchCreateUsersRequest struct {
+	Users  []CreateUserRequest `json:"users" binding:"required,min=1,dive"`
+	Atomic bool                `json:"atomic"`
+}
+
+// BatchUpdateUserRequest represents an item of a bulk user update
+// The version is optional, and makes the update conditional like If-Match.
+type BatchUpdateUserRequest struct {
+	ID      uuid.UUID `json:"id" binding:"required"`
+	Name    string    `json:"name" binding:"required"`
+	Email   string    `json:"email" binding:"required,email"`
+	Version int64     `json:"version" binding:"min=0"`
+}
+
+// BatchUpdateUsersRequest represents the request body for updating users in bulk.
+type BatchUpdateUsersRequest struct {
+	Users  []BatchUpdateUserRequest `json:"users" binding:"required,min=1,dive"`
+	Atomic bool                     `json:"atomic"`
+}
+
+// BatchDeleteUserRequest represents an item of a bulk user deletion.
+type BatchDeleteUserRequest struct {
+	ID      uuid.UUID `json:"id" binding:"required"`
+	Version int64     `json:"version" binding:"min=0"`
+}
+
+// BatchDeleteUsersRequest represents the request body for deleting users in bulk.
+type BatchDeleteUsersRequest struct {
+	Users  []BatchDeleteUserRequest `json:"users" binding:"required,min=1,dive"`
+	Atomic bool                     `json:"atomic"`
+}
+
 // UserResponse represents the response body for user operations
 // This demonstrates how to define response DTOs.
 type UserResponse struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int64      `json:"version"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// UserListResponse represents the response body for user listings
+// Cursors are null when there is no page in that direction.
+type UserListResponse struct {
+	Users      []UserResponse `json:"users"`
+	Limit      int            `json:"limit"`
+	NextCursor *string        `json:"next_cursor"`
+	PrevCursor *string        `json:"prev_cursor"`
+	Total      *int64         `json:"total,omitempty"`
+}
+
+// BatchItemResult represents the outcome of an item of a bulk operation
+// Status is the HTTP status the item would have had as a single request.
+type BatchItemResult struct {
+	Index  int           `json:"index"`
+	Status int           `json:"status"`
+	User   *UserResponse `json:"user,omitempty"`
+	Error  *Problem      `json:"error,omitempty"`
+}
+
+// BatchResponse represents the response body for bulk operations.
+type BatchResponse struct {
+	Results   []BatchItemResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+}
+
+// WebhookRequest represents the request body for creating or updating a webhook
+// Every event is delivered when event_types is empty, and the secret is generated when omitted on creation.
+// Omitting the secret of an update keeps the current one.
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required,http_url"`
+	EventTypes []string `json:"event_types" binding:"dive,oneof=user.created user.updated user.deleted"`
+	Secret     string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	Active     *bool    `json:"active"`
+}
+
+// WebhookResponse represents the response body for webhook operations
+// The secret is only returned when it is set, on creation and rotation.
+type WebhookResponse struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookListResponse represents the response body for webhook listings.
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookDeliveryResponse represents a webhook delivery, with the outcome of its last attempt.
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveryListResponse represents the response body for the delivery log of a webhook.
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Limit      int                       `json:"limit"`
 }
 
 // HealthResponse represents the response body for health checks.
 type HealthResponse struct {
 	Status  string `json:"status"`
 	Service string `json:"service"`
+	// Cache holds the user cache counters, when the cache is enabled.
+	Cache *CacheStatsResponse `json:"cache,omitempty"`
+}
+
+// CacheStatsResponse represents the counters of a cache.
+type CacheStatsResponse struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// ReadinessResponse represents the response body for readiness checks.
+type ReadinessResponse struct {
+	Status  string            `json:"status"`
+	Service string            `json:"service"`
+	Checks  map[string]string `json:"checks,omitempty"`
 }
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+// defaultMaxBatchSize is used when no maximum batch size is configured.
+const defaultMaxBatchSize = 1000
+
+// batchUsers dispatches the custom methods of the users collection, e.g. `POST /users:batchCreate`
+// gin cannot route a literal colon inside a segment, so the method name is a path parameter.
+func (h *UserHandler) batchUsers(c *gin.Context) {
+	switch c.Param("action") {
+	case ":batchCreate":
+		h.batchCreateUsers(c)
+	case ":batchUpdate":
+		h.batchUpdateUsers(c)
+	case ":batchDelete":
+		h.batchDeleteUsers(c)
+	default:
+		respondProblem(c, http.StatusNotFound, "No route matches {0}", c.Request.Method+" "+c.Request.URL.Path)
+	}
+}
+
+// batchCreateUsers handles bulk user creation requests
+// This demonstrates how to implement a bulk endpoint reporting the outcome of every item.
+func (h *UserHandler) batchCreateUsers(c *gin.Context) {
+	var req BatchCreateUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !h.checkBatchSize(c, len(req.Users)) {
+		return
+	}
+
+	users := make([]*repositories.User, len(req.Users))
+	for i, item := range req.Users {
+		users[i] = &repositories.User{Name: item.Name, Email: item.Email}
+	}
+
+	results, err := h.userRepo.BatchCreateUsers(c.Request.Context(), users, req.Atomic)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to create users")
+		return
+	}
+
+	h.respondBatch(c, results, req.Atomic, http.StatusCreated, "Failed to create user")
+}
+
+// batchUpdateUsers handles bulk user update requests.
+func (h *UserHandler) batchUpdateUsers(c *gin.Context) {
+	var req BatchUpdateUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !h.checkBatchSize(c, len(req.Users)) {
+		return
+	}
+
+	users := make([]*repositories.User, len(req.Users))
+	for i, item := range req.Users {
+		users[i] = &repositories.User{ID: item.ID, Name: item.Name, Email: item.Email, Version: item.Version}
+	}
+
+	results, err := h.userRepo.BatchUpdateUsers(c.Request.Context(), users, req.Atomic)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to update users")
+		return
+	}
+
+	h.respondBatch(c, results, req.Atomic, http.StatusOK, "Failed to update user")
+}
+
+// batchDeleteUsers handles bulk user deletion requests.
+func (h *UserHandler) batchDeleteUsers(c *gin.Context) {
+	var req BatchDeleteUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !h.checkBatchSize(c, len(req.Users)) {
+		return
+	}
+
+	refs := make([]repositories.UserRef, len(req.Users))
+	for i, item := range req.Users {
+		refs[i] = repositories.UserRef{ID: item.ID, Version: item.Version}
+	}
+
+	results, err := h.userRepo.BatchDeleteUsers(c.Request.Context(), refs, req.Atomic)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to delete users")
+		return
+	}
+
+	h.respondBatch(c, results, req.Atomic, http.StatusNoContent, "Failed to delete user")
+}
+
+// checkBatchSize writes a 400 response and returns false when a batch holds too many items.
+func (h *UserHandler) checkBatchSize(c *gin.Context, size int) bool {
+	limit := h.config.Server.MaxBatchSize
+	if limit <= 0 {
+		limit = defaultMaxBatchSize
+	}
+
+	if size > limit {
+		respondProblem(c, http.StatusBadRequest, "A batch holds at most {0} items", strconv.Itoa(limit))
+		return false
+	}
+
+	return true
+}
+
+// respondBatch writes the outcome of every item of a bulk operation
+// The response is a 200 when every item succeeded, a 207 when only some did,
+// and a 422 when an atomic batch was rolled back.
+func (h *UserHandler) respondBatch(c *gin.Context, results []repositories.BatchResult, atomic bool, successStatus int, message string) {
+	response := BatchResponse{Results: make([]BatchItemResult, len(results))}
+
+	for i, result := range results {
+		item := BatchItemResult{Index: i, Status: successStatus}
+
+		switch {
+		case result.Err != nil:
+			item.Error = errorProblem(c, h.logger, result.Err, message)
+			item.Status = item.Error.Status
+			response.Failed++
+		case result.User != nil && successStatus != http.StatusNoContent:
+			item.User = &UserResponse{
+				ID:        result.User.ID,
+				Name:      result.User.Name,
+				Email:     result.User.Email,
+				CreatedAt: result.User.CreatedAt,
+				UpdatedAt: result.User.UpdatedAt,
+				Version:   result.User.Version,
+				DeletedAt: result.User.DeletedAt,
+			}
+			response.Succeeded++
+		default:
+			response.Succeeded++
+		}
+
+		response.Results[i] = item
+	}
+
+	status := http.StatusOK
+	switch {
+	case response.Failed > 0 && atomic:
+		status = http.StatusUnprocessableEntity
+	case response.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, response)
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+// batchUserRepository fails the creation of users named "conflict".
+type batchUserRepository struct {
+	repositories.UserRepository
+}
+
+func (r *batchUserRepository) BatchCreateUsers(_ context.Context, users []*repositories.User, atomic bool) ([]repositories.BatchResult, error) {
+	results := make([]repositories.BatchResult, len(users))
+	for i, user := range users {
+		results[i].User = user
+		if user.Name == "conflict" {
+			results[i] = repositories.BatchResult{Err: repositories.ErrConflict}
+		}
+	}
+
+	if atomic {
+		for i := range results {
+			if results[i].Err == nil && len(users) > 1 {
+				results[i] = repositories.BatchResult{Err: repositories.ErrAborted}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func TestBatchCreateUsers(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	setupValidator()
+
+	logger := zerolog.Nop()
+	handler := &UserHandler{
+		config:   &config.Config{Server: config.ServerConfig{MaxBatchSize: 2}},
+		userRepo: &batchUserRepository{},
+		logger:   &logger,
+	}
+
+	engine := gin.New()
+	engine.POST("/users:action", handler.batchUsers)
+
+	testCases := map[string]struct {
+		body     string
+		status   int
+		statuses []int
+	}{
+		"all created": {
+			body:     `{"users":[{"name":"John","email":"john@example.com"},{"name":"Jane","email":"jane@example.com"}]}`,
+			status:   http.StatusOK,
+			statuses: []int{http.StatusCreated, http.StatusCreated},
+		},
+		"partial": {
+			body:     `{"users":[{"name":"John","email":"john@example.com"},{"name":"conflict","email":"jane@example.com"}]}`,
+			status:   http.StatusMultiStatus,
+			statuses: []int{http.StatusCreated, http.StatusConflict},
+		},
+		"atomic": {
+			body:     `{"atomic":true,"users":[{"name":"John","email":"john@example.com"},{"name":"conflict","email":"jane@example.com"}]}`,
+			status:   http.StatusUnprocessableEntity,
+			statuses: []int{http.StatusFailedDependency, http.StatusConflict},
+		},
+		"too large": {
+			body:   `{"users":[{"name":"A","email":"a@example.com"},{"name":"B","email":"b@example.com"},{"name":"C","email":"c@example.com"}]}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users:batchCreate", strings.NewReader(tc.body)))
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.statuses == nil {
+				return
+			}
+
+			var response BatchResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+			}
+			for i, want := range tc.statuses {
+				if got := response.Results[i]; got.Index != i || got.Status != want {
+					t.Errorf("result %d = %+v, want status %d", i, got, want)
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users:frobnicate", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown method = %d, want 404", rec.Code)
+	}
+}
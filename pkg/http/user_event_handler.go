@@ -0,0 +1,131 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// defaultEventHeartbeat is used when no heartbeat interval is configured.
+	defaultEventHeartbeat = 15 * time.Second
+
+	// eventReset is sent to the clients resuming after an event which is no longer buffered.
+	eventReset = "reset"
+)
+
+// eventSubscriber subscribes to the user events, it is implemented by repositories.UserEventStream.
+type eventSubscriber interface {
+	Subscribe(lastEventID string) (*repositories.UserEventSubscription, func())
+}
+
+// UserEventHandler streams the user changes as Server-Sent Events.
+type UserEventHandler struct {
+	stream    eventSubscriber
+	logger    *zerolog.Logger
+	heartbeat time.Duration
+	// closing is closed when the server shuts down, since streams never end on their own
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewUserEventHandler creates a new UserEventHandler
+// It fails when the database driver does not stream user events, in which case the route is not registered.
+func NewUserEventHandler(injector do.Injector) (*UserEventHandler, error) {
+	stream, err := do.Invoke[*repositories.UserEventStream](injector)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeat := time.Duration(do.MustInvoke[*config.Config](injector).Server.EventHeartbeat) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = defaultEventHeartbeat
+	}
+
+	return &UserEventHandler{
+		stream:    stream,
+		logger:    do.MustInvoke[*zerolog.Logger](injector),
+		heartbeat: heartbeat,
+		closing:   make(chan struct{}),
+	}, nil
+}
+
+// streamUserEvents handles event stream requests, sending every user change until the client disconnects
+// Clients reconnecting with a Last-Event-ID header get the events they missed, or a reset event when they are no longer buffered.
+func (h *UserEventHandler) streamUserEvents(c *gin.Context) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+
+	subscription, unsubscribe := h.stream.Subscribe(lastEventID)
+	defer unsubscribe()
+
+	// Streams outlive the write timeout of the server
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Debug().Err(err).Msg("Failed to clear the write deadline of an event stream")
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// Proxies must not buffer the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !subscription.Resumed {
+		if err := sse.Encode(c.Writer, sse.Event{Event: eventReset, Data: gin.H{"last_event_id": lastEventID}}); err != nil {
+			return
+		}
+	}
+	for _, event := range subscription.Replay {
+		if err := writeUserEvent(c.Writer, event); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-h.closing:
+			return
+		case event, ok := <-subscription.Events:
+			// The client fell behind, it resumes from the replay buffer once it reconnects
+			if !ok {
+				return
+			}
+			if err := writeUserEvent(c.Writer, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			// Comments are ignored by clients, but keep idle connections open through proxies
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeUserEvent writes an event, identified by its ID and named after its type, with the user as data.
+func writeUserEvent(w io.Writer, event repositories.Event) error {
+	return sse.Encode(w, sse.Event{
+		Id:    event.ID.String(),
+		Event: event.Type,
+		Data:  event.Payload,
+	})
+}
+
+// close ends the streams in progress, so that the server can shut down gracefully.
+func (h *UserEventHandler) close() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+// eventSubscription returns a subscription holding the given events, ended once they are read.
+type eventSubscription struct {
+	replay  []repositories.Event
+	events  []repositories.Event
+	resumed bool
+}
+
+func (s *eventSubscription) Subscribe(string) (*repositories.UserEventSubscription, func()) {
+	events := make(chan repositories.Event, len(s.events))
+	for _, event := range s.events {
+		events <- event
+	}
+	close(events)
+
+	return &repositories.UserEventSubscription{Replay: s.replay, Resumed: s.resumed, Events: events}, func() {}
+}
+
+func TestStreamUserEvents(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	replayed := repositories.Event{ID: uuid.New(), Type: repositories.EventUserUpdated, Payload: []byte(`{"name":"John"}`)}
+	notified := repositories.Event{ID: uuid.New(), Type: repositories.EventUserDeleted, Payload: []byte(`{"name":"Jane"}`)}
+
+	testCases := map[string]struct {
+		subscription *eventSubscription
+		want         []string
+	}{
+		"resumed": {
+			subscription: &eventSubscription{replay: []repositories.Event{replayed}, events: []repositories.Event{notified}, resumed: true},
+			want: []string{
+				"id:" + replayed.ID.String() + "\nevent:user.updated\ndata:{\"name\":\"John\"}\n\n",
+				"id:" + notified.ID.String() + "\nevent:user.deleted\ndata:{\"name\":\"Jane\"}\n\n",
+			},
+		},
+		"reset": {
+			subscription: &eventSubscription{events: []repositories.Event{notified}},
+			want: []string{
+				"event:reset\ndata:{\"last_event_id\":\"" + replayed.ID.String() + "\"}\n\n",
+				"id:" + notified.ID.String() + "\n",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := zerolog.Nop()
+			handler := &UserEventHandler{stream: tc.subscription, logger: &logger, heartbeat: time.Hour, closing: make(chan struct{})}
+
+			engine := gin.New()
+			engine.GET("/users/events", handler.streamUserEvents)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/events", nil)
+			req.Header.Set("Last-Event-ID", replayed.ID.String())
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+				t.Fatalf("response = %d %q, want an event stream", rec.Code, rec.Header().Get("Content-Type"))
+			}
+
+			body := rec.Body.String()
+			offset := 0
+			for _, want := range tc.want {
+				i := strings.Index(body[offset:], want)
+				if i < 0 {
+					t.Fatalf("body = %q, want %q after offset %d", body, want, offset)
+				}
+				offset += i + len(want)
+			}
+		})
+	}
+}
+
+func TestStreamUserEventsHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	// The subscription stays open until the handler is closed
+	events := make(chan repositories.Event)
+	logger := zerolog.Nop()
+	handler := &UserEventHandler{
+		stream:    subscriberFunc(func() <-chan repositories.Event { return events }),
+		logger:    &logger,
+		heartbeat: 10 * time.Millisecond,
+		closing:   make(chan struct{}),
+	}
+
+	engine := gin.New()
+	engine.GET("/users/events", handler.streamUserEvents)
+
+	time.AfterFunc(50*time.Millisecond, handler.close)
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/events", nil))
+
+	if !strings.Contains(rec.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("body = %q, want heartbeat comments", rec.Body.String())
+	}
+}
+
+// subscriberFunc subscribes to the events of the channel it returns.
+type subscriberFunc func() <-chan repositories.Event
+
+func (f subscriberFunc) Subscribe(string) (*repositories.UserEventSubscription, func()) {
+	return &repositories.UserEventSubscription{Resumed: true, Events: f()}, func() {}
+}
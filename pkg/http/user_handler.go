@@ -1,20 +1,36 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
 	"github.com/samber/do-template-api/pkg/repositories"
 	"github.com/samber/do/v2"
 )
 
+// defaultPageSize is used when no default page size is configured.
+const defaultPageSize = 20
+
 // UserHandler handles HTTP requests for user operations
 // This demonstrates how to organize handlers in a separate struct.
 type UserHandler struct {
+	config   *config.Config              `do:""`
 	userRepo repositories.UserRepository `do:""`
-	logger   zerolog.Logger              `do:""`
+	logger   *zerolog.Logger             `do:""`
 }
 
 // NewUserHandler creates a new UserHandler with dependency injection
@@ -28,7 +44,7 @@ func NewUserHandler(injector do.Injector) (*UserHandler, error) {
 func (h *UserHandler) createUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -39,34 +55,43 @@ func (h *UserHandler) createUser(c *gin.Context) {
 
 	createdUser, err := h.userRepo.CreateUser(c.Request.Context(), user)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to create user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		respondError(c, h.logger, err, "Failed to create user")
 		return
 	}
 
+	c.Header("ETag", etag(createdUser.Version))
 	c.JSON(http.StatusCreated, UserResponse{
 		ID:        createdUser.ID,
 		Name:      createdUser.Name,
 		Email:     createdUser.Email,
 		CreatedAt: createdUser.CreatedAt,
 		UpdatedAt: createdUser.UpdatedAt,
+		Version:   createdUser.Version,
 	})
 }
 
 // getUser handles user retrieval requests
 // This demonstrates how to implement READ endpoint with dependency injection.
 func (h *UserHandler) getUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, ok := readContext(c)
+	if !ok {
 		return
 	}
 
-	user, err := h.userRepo.GetUserByID(c.Request.Context(), id)
+	user, err := h.userRepo.GetUserByID(ctx, id)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to get user")
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, h.logger, err, "Failed to get user")
+		return
+	}
+
+	c.Header("ETag", etag(user.Version))
+	if notModified(c, user.Version) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
@@ -76,102 +101,436 @@ func (h *UserHandler) getUser(c *gin.Context) {
 		Email:     user.Email,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
+		DeletedAt: user.DeletedAt,
 	})
 }
 
 // listUsers handles user listing requests
-// This demonstrates how to implement LIST endpoint with dependency injection.
+// This demonstrates how to implement LIST endpoint with cursor pagination.
 func (h *UserHandler) listUsers(c *gin.Context) {
-	limit := 20
-	offset := 0
+	params := repositories.ListUsersParams{Limit: pageSize(h.config, c.Query("limit"))}
+
+	filter, ok := parseUserFilter(c)
+	if !ok {
+		return
+	}
+	params.Filter = filter
+
+	sort, err := repositories.ParseUserSort(c.Query("sort"))
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "Invalid sort, expected a comma separated list of name, email, created_at or updated_at, prefixed by - for a descending order")
+		return
+	}
+	params.Sort = sort
 
-	if l, err := strconv.Atoi(c.DefaultQuery("limit", "20")); err == nil && l > 0 {
-		limit = l
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := repositories.DecodeCursor(raw)
+		if err != nil || cursor.Sort != repositories.FormatUserSort(sort) {
+			respondProblem(c, http.StatusBadRequest, "Invalid pagination cursor")
+			return
+		}
+		params.Cursor = cursor
+	}
+
+	total, err := repositories.ParseTotalMode(c.Query("total"))
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "Invalid total mode, expected exact or estimated")
+		return
 	}
+	params.Total = total
 
-	if o, err := strconv.Atoi(c.DefaultQuery("offset", "0")); err == nil && o >= 0 {
-		offset = o
+	ctx, ok := readContext(c)
+	if !ok {
+		return
 	}
 
-	users, err := h.userRepo.ListUsers(c.Request.Context(), limit, offset)
+	page, err := h.userRepo.ListUsers(ctx, params)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to list users")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		respondError(c, h.logger, err, "Failed to list users")
 		return
 	}
 
-	response := make([]UserResponse, len(users))
-	for i, user := range users {
-		response[i] = UserResponse{
+	response := UserListResponse{
+		Users: make([]UserResponse, len(page.Users)),
+		Limit: params.Limit,
+		Total: page.Total,
+	}
+	for i, user := range page.Users {
+		response.Users[i] = UserResponse{
 			ID:        user.ID,
 			Name:      user.Name,
 			Email:     user.Email,
 			CreatedAt: user.CreatedAt,
 			UpdatedAt: user.UpdatedAt,
+			Version:   user.Version,
+			DeletedAt: user.DeletedAt,
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"users":  response,
-		"limit":  limit,
-		"offset": offset,
-	})
+	links := []string{pageLink(c, nil, "first")}
+	if page.NextCursor != nil {
+		cursor := page.NextCursor.Encode()
+		response.NextCursor = &cursor
+		links = append(links, pageLink(c, &cursor, "next"))
+	}
+	if page.PrevCursor != nil {
+		cursor := page.PrevCursor.Encode()
+		response.PrevCursor = &cursor
+		links = append(links, pageLink(c, &cursor, "prev"))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+
+	c.JSON(http.StatusOK, response)
+}
+
+// parseUserFilter parses the filters of a users listing from the query parameters
+// It writes a 400 response and returns false when a parameter is malformed.
+func parseUserFilter(c *gin.Context) (repositories.UserFilter, bool) {
+	filter := repositories.UserFilter{
+		NamePrefix:  c.Query("name"),
+		EmailDomain: c.Query("email_domain"),
+		Query:       strings.TrimSpace(c.Query("q")),
+	}
+
+	timestamps := map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+		"updated_after":  &filter.UpdatedAfter,
+		"updated_before": &filter.UpdatedBefore,
+	}
+	for param, target := range timestamps {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondProblem(c, http.StatusBadRequest, "Invalid {0} parameter, expected an RFC 3339 timestamp", param)
+			return filter, false
+		}
+		*target = &t
+	}
+
+	return filter, true
+}
+
+// pageSize parses the `limit` query parameter, capped to the configured maximum page size.
+func pageSize(cfg *config.Config, raw string) int {
+	size := cfg.Server.DefaultPageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	if l, err := strconv.Atoi(raw); err == nil && l > 0 {
+		size = l
+	}
+
+	if cfg.Server.MaxPageSize > 0 {
+		size = min(size, cfg.Server.MaxPageSize)
+	}
+
+	return size
+}
+
+// pageLink formats an RFC 8288 link to the page at cursor, keeping the other query parameters
+// A nil cursor links to the first page.
+func pageLink(c *gin.Context, cursor *string, rel string) string {
+	query := c.Request.URL.Query()
+	query.Del("cursor")
+	if cursor != nil {
+		query.Set("cursor", *cursor)
+	}
+
+	target := c.Request.URL.Path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, target, rel)
 }
 
 // updateUser handles user update requests
 // This demonstrates how to implement UPDATE endpoint with dependency injection.
 func (h *UserHandler) updateUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	version, ok := h.ifMatchVersion(c, id)
+	if !ok {
 		return
 	}
 
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
 	user := &repositories.User{
-		ID:    id,
-		Name:  req.Name,
-		Email: req.Email,
+		ID:      id,
+		Name:    req.Name,
+		Email:   req.Email,
+		Version: version,
 	}
 
 	updatedUser, err := h.userRepo.UpdateUser(c.Request.Context(), user)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to update user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		respondError(c, h.logger, err, "Failed to update user")
 		return
 	}
 
+	c.Header("ETag", etag(updatedUser.Version))
 	c.JSON(http.StatusOK, UserResponse{
 		ID:        updatedUser.ID,
 		Name:      updatedUser.Name,
 		Email:     updatedUser.Email,
 		CreatedAt: updatedUser.CreatedAt,
 		UpdatedAt: updatedUser.UpdatedAt,
+		Version:   updatedUser.Version,
+	})
+}
+
+// ifMatchVersion returns the version of user id which the write must apply to, 0 meaning any version
+// The repository checks a single version atomically. When If-Match lists several versions, the current one is read first,
+// and checked again by the repository so that a concurrent write still fails the precondition.
+func (h *UserHandler) ifMatchVersion(c *gin.Context, id uuid.UUID) (int64, bool) {
+	versions, ok := ifMatchVersions(c, h.config.Server.RequireIfMatch)
+	switch {
+	case !ok:
+		return 0, false
+	case len(versions) == 0:
+		return 0, true
+	case len(versions) == 1:
+		return versions[0], true
+	}
+
+	// Soft-deleted users are read as well, so that restorations can be conditional
+	user, err := h.userRepo.GetUserByID(repositories.WithDeleted(repositories.WithPrimary(c.Request.Context())), id)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to get user")
+		return 0, false
+	}
+	if !slices.Contains(versions, user.Version) {
+		respondProblem(c, http.StatusPreconditionFailed, "user has been modified since it was read")
+		return 0, false
+	}
+
+	return user.Version, true
+}
+
+// patchUser handles partial user update requests
+// The body is either a JSON Merge Patch or a JSON Patch, applied to the current user then validated like a full update.
+func (h *UserHandler) patchUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	versions, ok := ifMatchVersions(c, h.config.Server.RequireIfMatch)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// The patch applies to the latest state, which replicas may not have received yet
+	user, err := h.userRepo.GetUserByID(repositories.WithPrimary(c.Request.Context()), id)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to get user")
+		return
+	}
+	if versions != nil && !slices.Contains(versions, user.Version) {
+		respondProblem(c, http.StatusPreconditionFailed, "user has been modified since it was read")
+		return
+	}
+
+	doc, err := json.Marshal(UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
+	})
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to update user")
+		return
+	}
+
+	patched, err := applyPatch(c.GetHeader("Content-Type"), doc, body)
+	switch {
+	case errors.Is(err, errUnsupportedPatch):
+		c.Header("Accept-Patch", acceptPatch)
+		respondProblem(c, http.StatusUnsupportedMediaType, "Unsupported patch format, expected {0}", acceptPatch)
+		return
+	case errors.Is(err, errMalformedPatch):
+		respondProblem(c, http.StatusBadRequest, "The patch document is not valid")
+		return
+	case err != nil:
+		respondProblem(c, http.StatusUnprocessableEntity, "The patch cannot be applied to the user")
+		return
+	}
+
+	if !onlyFieldsChanged(doc, patched, "name", "email") {
+		respondProblem(c, http.StatusUnprocessableEntity, "Only the name and email of a user can be changed")
+		return
+	}
+
+	// The patched user must satisfy the same rules as a full update
+	var req UpdateUserRequest
+	if err := json.Unmarshal(patched, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// The patch was computed from this version, so it must not apply to another one
+	patch := repositories.UserPatch{Version: user.Version}
+	if req.Name != user.Name {
+		patch.Name = &req.Name
+	}
+	if req.Email != user.Email {
+		patch.Email = &req.Email
+	}
+
+	patchedUser, err := h.userRepo.PatchUser(c.Request.Context(), id, patch)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to update user")
+		return
+	}
+
+	c.Header("ETag", etag(patchedUser.Version))
+	c.JSON(http.StatusOK, UserResponse{
+		ID:        patchedUser.ID,
+		Name:      patchedUser.Name,
+		Email:     patchedUser.Email,
+		CreatedAt: patchedUser.CreatedAt,
+		UpdatedAt: patchedUser.UpdatedAt,
+		Version:   patchedUser.Version,
 	})
 }
 
+// onlyFieldsChanged reports whether the JSON objects before and after only differ by the given fields.
+func onlyFieldsChanged(before, after []byte, fields ...string) bool {
+	var b, a map[string]any
+	if json.Unmarshal(before, &b) != nil || json.Unmarshal(after, &a) != nil {
+		return false
+	}
+
+	for _, field := range fields {
+		delete(b, field)
+		delete(a, field)
+	}
+
+	return reflect.DeepEqual(b, a)
+}
+
 // deleteUser handles user deletion requests
-// This demonstrates how to implement DELETE endpoint with dependency injection.
+// Users are soft-deleted, and can be restored with the restore custom method.
 func (h *UserHandler) deleteUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	version, ok := h.ifMatchVersion(c, id)
+	if !ok {
+		return
+	}
+
+	if err := h.userRepo.DeleteUser(c.Request.Context(), id, version); err != nil {
+		respondError(c, h.logger, err, "Failed to delete user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// userAction dispatches the custom methods of a user, e.g. `POST /users/:id:restore`
+// Like batchUsers, the method name is parsed out of the path parameter.
+func (h *UserHandler) userAction(c *gin.Context) {
+	id, action, _ := strings.Cut(c.Param("id"), ":")
+	switch action {
+	case "restore":
+		h.restoreUser(c, id)
+	default:
+		respondProblem(c, http.StatusNotFound, "No route matches {0}", c.Request.Method+" "+c.Request.URL.Path)
+	}
+}
+
+// restoreUser handles soft-deleted user restoration requests
+// Restoring a user that is not deleted is a conflict, like creating a user whose email address is taken.
+func (h *UserHandler) restoreUser(c *gin.Context, rawID string) {
+	id, err := uuid.Parse(rawID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondProblem(c, http.StatusBadRequest, "Invalid user ID, expected a UUID")
 		return
 	}
 
-	err = h.userRepo.DeleteUser(c.Request.Context(), id)
+	version, ok := h.ifMatchVersion(c, id)
+	if !ok {
+		return
+	}
+
+	user, err := h.userRepo.RestoreUser(c.Request.Context(), id, version)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to delete user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		respondError(c, h.logger, err, "Failed to restore user")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
+	})
+}
+
+// readContext returns the context of a read, which includes soft-deleted users with `?include_deleted=true`
+// It writes a 400 response and returns false when the parameter is malformed.
+func readContext(c *gin.Context) (context.Context, bool) {
+	included, err := parseBoolQuery(c, "include_deleted")
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "Invalid {0} parameter, expected true or false", "include_deleted")
+		return nil, false
+	}
+
+	if included {
+		return repositories.WithDeleted(c.Request.Context()), true
+	}
+	return c.Request.Context(), true
+}
+
+// parseBoolQuery parses an optional boolean query parameter, which is false when absent.
+func parseBoolQuery(c *gin.Context, name string) (bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(raw)
+}
+
+// parseUserID parses the `:id` path parameter as a UUID
+// It writes a 400 response and returns false when the ID is malformed.
+func parseUserID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "Invalid user ID, expected a UUID")
+		return uuid.Nil, false
+	}
+
+	return id, true
 }
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+// restoreUserRepository holds a single soft-deleted user.
+type restoreUserRepository struct {
+	repositories.UserRepository
+	deleted uuid.UUID
+}
+
+func (r *restoreUserRepository) RestoreUser(_ context.Context, id uuid.UUID, _ int64) (*repositories.User, error) {
+	if id != r.deleted {
+		return nil, repositories.ErrNotFound
+	}
+	return &repositories.User{ID: id, Name: "John", Email: "john@example.com", Version: 3}, nil
+}
+
+func TestRestoreUser(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	logger := zerolog.Nop()
+	deleted := uuid.New()
+	handler := &UserHandler{
+		config:   &config.Config{},
+		userRepo: &restoreUserRepository{deleted: deleted},
+		logger:   &logger,
+	}
+
+	engine := gin.New()
+	engine.POST("/users/:id", handler.userAction)
+
+	testCases := map[string]struct {
+		path   string
+		status int
+	}{
+		"restored":       {path: "/users/" + deleted.String() + ":restore", status: http.StatusOK},
+		"missing":        {path: "/users/" + uuid.NewString() + ":restore", status: http.StatusNotFound},
+		"invalid id":     {path: "/users/john:restore", status: http.StatusBadRequest},
+		"unknown method": {path: "/users/" + deleted.String() + ":frobnicate", status: http.StatusNotFound},
+		"no method":      {path: "/users/" + deleted.String(), status: http.StatusNotFound},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, nil))
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.status == http.StatusOK && rec.Header().Get("ETag") != etag(3) {
+				t.Errorf("ETag = %q, want %q", rec.Header().Get("ETag"), etag(3))
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// setupValidator makes validation errors report fields by their JSON name.
+func setupValidator() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// jsonFieldName returns the JSON name of a struct field.
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
+}
+
+// respondBindError writes the problem matching a request body binding error
+// Raw decoder and validator messages are never exposed, each failing field is described instead.
+func respondBindError(c *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &validationErrors):
+		problem := newProblem(c, http.StatusBadRequest, "The request body failed validation")
+		problem.Type = problemTypeValidation
+		for _, fe := range validationErrors {
+			problem.Errors = append(problem.Errors, FieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Param:   fe.Param(),
+				Message: translateFieldError(c, fe),
+			})
+		}
+		writeProblem(c, problem)
+	case errors.As(err, &typeError):
+		problem := newProblem(c, http.StatusBadRequest, "The request body failed validation")
+		problem.Type = problemTypeValidation
+		problem.Errors = []FieldError{{
+			Field:   typeError.Field,
+			Rule:    "type",
+			Param:   typeError.Type.String(),
+			Message: translate(c, "{0} must be of type {1}", typeError.Field, typeError.Type.String()),
+		}}
+		writeProblem(c, problem)
+	default:
+		respondProblem(c, http.StatusBadRequest, "The request body is not valid JSON")
+	}
+}
+
+// fieldPath returns the path of a failing field from the request body root, e.g. `users[2].email`.
+func fieldPath(fe validator.FieldError) string {
+	// The namespace starts with the name of the request struct
+	if _, path, ok := strings.Cut(fe.Namespace(), "."); ok {
+		return path
+	}
+	return fe.Field()
+}
+
+// validationMessage describes a failing validation rule in plain English.
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "email":
+		return fe.Field() + " must be a valid email address"
+	case "http_url":
+		return fe.Field() + " must be a valid HTTP or HTTPS URL"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", fe.Field(), strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "min":
+		if isCollection(fe) {
+			return fmt.Sprintf("%s must hold at least %s items", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s characters long", fe.Field(), fe.Param())
+	case "max":
+		if isCollection(fe) {
+			return fmt.Sprintf("%s must hold at most %s items", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s characters long", fe.Field(), fe.Param())
+	default:
+		return fmt.Sprintf("%s failed on the %s rule", fe.Field(), fe.Tag())
+	}
+}
+
+// isCollection reports whether the length rules of a field count items rather than characters.
+func isCollection(fe validator.FieldError) bool {
+	switch fe.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondBindError(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	setupValidator()
+
+	engine := gin.New()
+	engine.Use(requestIDMiddleware())
+	engine.POST("/users", func(c *gin.Context) {
+		var req CreateUserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	testCases := map[string]struct {
+		body   string
+		fields map[string]string
+	}{
+		"missing fields": {
+			body:   `{}`,
+			fields: map[string]string{"name": "required", "email": "required"},
+		},
+		"invalid email": {
+			body:   `{"name":"John","email":"nope"}`,
+			fields: map[string]string{"email": "email"},
+		},
+		"wrong type": {
+			body:   `{"name":42,"email":"john@example.com"}`,
+			fields: map[string]string{"name": "type"},
+		},
+		"malformed": {
+			body:   `{"name":`,
+			fields: map[string]string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tc.body))
+			req.Header.Set(requestIDHeader, "req-1")
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("unexpected content type: %s", ct)
+			}
+
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if problem.Status != http.StatusBadRequest || problem.Instance != "/users" || problem.RequestID != "req-1" {
+				t.Errorf("unexpected problem: %+v", problem)
+			}
+
+			fields := map[string]string{}
+			for _, fe := range problem.Errors {
+				fields[fe.Field] = fe.Rule
+			}
+			if len(fields) != len(tc.fields) {
+				t.Errorf("expected field errors %v, got %v", tc.fields, fields)
+			}
+			for field, rule := range tc.fields {
+				if fields[field] != rule {
+					t.Errorf("expected %s to fail on %s, got %q", field, rule, fields[field])
+				}
+			}
+		})
+	}
+}
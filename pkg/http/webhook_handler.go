@@ -0,0 +1,255 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
+	"github.com/samber/do/v2"
+)
+
+// WebhookHandler handles HTTP requests for webhook subscriptions and their delivery log.
+type WebhookHandler struct {
+	config *config.Config
+	repo   repositories.WebhookRepository
+	logger *zerolog.Logger
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+// It fails when the database driver does not support webhooks, in which case the routes are not registered.
+func NewWebhookHandler(injector do.Injector) (*WebhookHandler, error) {
+	repo, err := do.Invoke[repositories.WebhookRepository](injector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookHandler{
+		config: do.MustInvoke[*config.Config](injector),
+		repo:   repo,
+		logger: do.MustInvoke[*zerolog.Logger](injector),
+	}, nil
+}
+
+// createWebhook handles webhook creation requests
+// The secret is only returned by this endpoint, partners rotate it with an update when it is lost.
+func (h *WebhookHandler) createWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	webhook, err := h.repo.CreateWebhook(c.Request.Context(), webhookFromRequest(req))
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to create webhook")
+		return
+	}
+
+	response := webhookResponse(webhook)
+	response.Secret = webhook.Secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// getWebhook handles webhook retrieval requests.
+func (h *WebhookHandler) getWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := h.repo.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to get webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookResponse(webhook))
+}
+
+// listWebhooks handles webhook listing requests.
+func (h *WebhookHandler) listWebhooks(c *gin.Context) {
+	webhooks, err := h.repo.ListWebhooks(c.Request.Context())
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to list webhooks")
+		return
+	}
+
+	response := WebhookListResponse{Webhooks: make([]WebhookResponse, len(webhooks))}
+	for i, webhook := range webhooks {
+		response.Webhooks[i] = webhookResponse(webhook)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// updateWebhook handles webhook update requests
+// The secret is kept when the request omits it.
+func (h *WebhookHandler) updateWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	webhook := webhookFromRequest(req)
+	webhook.ID = id
+
+	updated, err := h.repo.UpdateWebhook(c.Request.Context(), webhook)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to update webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookResponse(updated))
+}
+
+// deleteWebhook handles webhook deletion requests, the delivery log of the webhook is deleted along with it.
+func (h *WebhookHandler) deleteWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteWebhook(c.Request.Context(), id); err != nil {
+		respondError(c, h.logger, err, "Failed to delete webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// listWebhookDeliveries handles delivery log requests, returning the latest deliveries of a webhook.
+func (h *WebhookHandler) listWebhookDeliveries(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	// An unknown webhook is reported as such, rather than as an empty log
+	if _, err := h.repo.GetWebhook(c.Request.Context(), id); err != nil {
+		respondError(c, h.logger, err, "Failed to list webhook deliveries")
+		return
+	}
+
+	limit := pageSize(h.config, c.Query("limit"))
+	deliveries, err := h.repo.ListWebhookDeliveries(c.Request.Context(), id, limit)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to list webhook deliveries")
+		return
+	}
+
+	response := WebhookDeliveryListResponse{
+		Deliveries: make([]WebhookDeliveryResponse, len(deliveries)),
+		Limit:      limit,
+	}
+	for i, delivery := range deliveries {
+		response.Deliveries[i] = webhookDeliveryResponse(delivery)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// deliveryAction dispatches the custom methods of a delivery, e.g. `POST /webhooks/:id/deliveries/:delivery:redeliver`
+// Like userAction, the method name is parsed out of the path parameter.
+func (h *WebhookHandler) deliveryAction(c *gin.Context) {
+	deliveryID, action, _ := strings.Cut(c.Param("delivery"), ":")
+	switch action {
+	case "redeliver":
+		h.redeliverWebhookDelivery(c, deliveryID)
+	default:
+		respondProblem(c, http.StatusNotFound, "No route matches {0}", c.Request.Method+" "+c.Request.URL.Path)
+	}
+}
+
+// redeliverWebhookDelivery handles redelivery requests
+// A new delivery of the same event is enqueued, and accepted before it is attempted.
+func (h *WebhookHandler) redeliverWebhookDelivery(c *gin.Context, rawDeliveryID string) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(rawDeliveryID)
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "Invalid delivery ID, expected a UUID")
+		return
+	}
+
+	delivery, err := h.repo.RedeliverWebhookDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		respondError(c, h.logger, err, "Failed to redeliver webhook delivery")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, webhookDeliveryResponse(delivery))
+}
+
+// webhookFromRequest returns the webhook described by a request, active unless stated otherwise.
+func webhookFromRequest(req WebhookRequest) *repositories.Webhook {
+	webhook := &repositories.Webhook{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     req.Secret,
+		Active:     true,
+	}
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	return webhook
+}
+
+// webhookResponse returns the response describing a webhook, without its secret.
+func webhookResponse(webhook *repositories.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:         webhook.ID,
+		URL:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		Active:     webhook.Active,
+		CreatedAt:  webhook.CreatedAt,
+		UpdatedAt:  webhook.UpdatedAt,
+	}
+}
+
+// webhookDeliveryResponse returns the response describing a delivery
+// The next attempt is only reported while the delivery is pending.
+func webhookDeliveryResponse(delivery *repositories.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		WebhookID:      delivery.WebhookID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastAttemptAt:  delivery.LastAttemptAt,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+	if delivery.Status == repositories.WebhookDeliveryPending {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return response
+}
+
+// parseWebhookID parses the `:id` path parameter as a UUID
+// It writes a 400 response and returns false when the ID is malformed.
+func parseWebhookID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, http.StatusBadRequest, "Invalid webhook ID, expected a UUID")
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+// webhookRepository holds a single webhook, with a single delivery.
+type webhookRepository struct {
+	repositories.WebhookRepository
+	webhook  uuid.UUID
+	delivery uuid.UUID
+}
+
+func (r *webhookRepository) CreateWebhook(_ context.Context, webhook *repositories.Webhook) (*repositories.Webhook, error) {
+	created := *webhook
+	created.ID = r.webhook
+	if created.Secret == "" {
+		created.Secret = "whsec_generated"
+	}
+	return &created, nil
+}
+
+func (r *webhookRepository) GetWebhook(_ context.Context, id uuid.UUID) (*repositories.Webhook, error) {
+	if id != r.webhook {
+		return nil, repositories.ErrNotFound
+	}
+	return &repositories.Webhook{ID: id, URL: "https://example.com/hook", Secret: "whsec_generated", Active: true}, nil
+}
+
+func (r *webhookRepository) RedeliverWebhookDelivery(_ context.Context, webhookID, deliveryID uuid.UUID) (*repositories.WebhookDelivery, error) {
+	if webhookID != r.webhook || deliveryID != r.delivery {
+		return nil, repositories.ErrNotFound
+	}
+	return &repositories.WebhookDelivery{ID: uuid.New(), WebhookID: webhookID, Status: repositories.WebhookDeliveryPending}, nil
+}
+
+func TestCreateWebhook(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	setupValidator()
+
+	logger := zerolog.Nop()
+	handler := &WebhookHandler{
+		config: &config.Config{},
+		repo:   &webhookRepository{webhook: uuid.New()},
+		logger: &logger,
+	}
+
+	engine := gin.New()
+	engine.POST("/webhooks", handler.createWebhook)
+	engine.GET("/webhooks/:id", handler.getWebhook)
+
+	testCases := map[string]struct {
+		body   string
+		status int
+		rules  []string
+	}{
+		"every event":     {body: `{"url":"https://example.com/hook"}`, status: http.StatusCreated},
+		"filtered events": {body: `{"url":"https://example.com/hook","event_types":["user.created"]}`, status: http.StatusCreated},
+		"missing url":     {body: `{}`, status: http.StatusBadRequest, rules: []string{"required"}},
+		"invalid url":     {body: `{"url":"ftp://example.com"}`, status: http.StatusBadRequest, rules: []string{"http_url"}},
+		"unknown event":   {body: `{"url":"https://example.com/hook","event_types":["user.renamed"]}`, status: http.StatusBadRequest, rules: []string{"oneof"}},
+		"short secret":    {body: `{"url":"https://example.com/hook","secret":"short"}`, status: http.StatusBadRequest, rules: []string{"min"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(tc.body)))
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+
+			if tc.status == http.StatusCreated {
+				var response WebhookResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if !response.Active || response.Secret != "whsec_generated" {
+					t.Errorf("response = %+v, want an active webhook with its secret", response)
+				}
+				return
+			}
+
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			if len(problem.Errors) != len(tc.rules) {
+				t.Fatalf("errors = %+v, want rules %v", problem.Errors, tc.rules)
+			}
+			for i, rule := range tc.rules {
+				if problem.Errors[i].Rule != rule {
+					t.Errorf("errors[%d].rule = %q, want %q", i, problem.Errors[i].Rule, rule)
+				}
+			}
+		})
+	}
+
+	t.Run("secret is not returned by reads", func(t *testing.T) {
+		t.Parallel()
+
+		id := handler.repo.(*webhookRepository).webhook
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/webhooks/"+id.String(), nil))
+
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "secret") {
+			t.Errorf("GET = %d %s, want the webhook without its secret", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestRedeliverWebhookDelivery(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	logger := zerolog.Nop()
+	repo := &webhookRepository{webhook: uuid.New(), delivery: uuid.New()}
+	handler := &WebhookHandler{config: &config.Config{}, repo: repo, logger: &logger}
+
+	engine := gin.New()
+	engine.POST("/webhooks/:id/deliveries/:delivery", handler.deliveryAction)
+
+	base := "/webhooks/" + repo.webhook.String() + "/deliveries/"
+	testCases := map[string]struct {
+		path   string
+		status int
+	}{
+		"redelivered":         {path: base + repo.delivery.String() + ":redeliver", status: http.StatusAccepted},
+		"missing delivery":    {path: base + uuid.NewString() + ":redeliver", status: http.StatusNotFound},
+		"other webhook":       {path: "/webhooks/" + uuid.NewString() + "/deliveries/" + repo.delivery.String() + ":redeliver", status: http.StatusNotFound},
+		"invalid delivery id": {path: base + "first:redeliver", status: http.StatusBadRequest},
+		"invalid webhook id":  {path: "/webhooks/first/deliveries/" + repo.delivery.String() + ":redeliver", status: http.StatusBadRequest},
+		"unknown method":      {path: base + repo.delivery.String() + ":frobnicate", status: http.StatusNotFound},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, nil))
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+		})
+	}
+}
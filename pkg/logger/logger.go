@@ -24,7 +24,7 @@ func NewLogger(i do.Injector) (*zerolog.Logger, error) {
 
 	// Configure log level
 	level, err := zerolog.ParseLevel(config.Logger.Level)
-	if err != nil {
+	if err != nil || level == zerolog.NoLevel {
 		level = zerolog.InfoLevel
 	}
 
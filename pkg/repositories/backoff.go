@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff computes the jittered exponential delays between the attempts of a failing operation
+// It is shared by the database connection, the transaction retries, the listeners, the outbox relay and the webhook deliveries.
+type Backoff struct {
+	// Initial is the delay before the first retry, doubled for every following retry
+	Initial time.Duration
+	// Max caps the delay when set
+	Max time.Duration
+}
+
+// Delay returns the jittered exponential delay before the given retry (starting at 1).
+func (b Backoff) Delay(retry int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < retry && (b.Max <= 0 || delay < b.Max) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// Equal jitter: keep half of the delay, randomize the other half
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// DurationOr returns d, or fallback when d is not positive
+// Configured durations use it to fall back to their default.
+func DurationOr(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		backoff Backoff
+		retry   int
+		min     time.Duration
+		max     time.Duration
+	}{
+		"first retry":       {backoff: Backoff{Initial: 100 * time.Millisecond, Max: time.Second}, retry: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		"doubled":           {backoff: Backoff{Initial: 100 * time.Millisecond, Max: time.Second}, retry: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		"capped":            {backoff: Backoff{Initial: 100 * time.Millisecond, Max: time.Second}, retry: 10, min: 500 * time.Millisecond, max: time.Second},
+		"initial above cap": {backoff: Backoff{Initial: 5 * time.Second, Max: time.Second}, retry: 1, min: 500 * time.Millisecond, max: time.Second},
+		"uncapped":          {backoff: Backoff{Initial: 100 * time.Millisecond}, retry: 4, min: 400 * time.Millisecond, max: 800 * time.Millisecond},
+		"no overflow":       {backoff: Backoff{Initial: time.Second}, retry: 1000, min: math.MaxInt64 / 4, max: math.MaxInt64},
+		"no backoff":        {backoff: Backoff{Max: time.Second}, retry: 5},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The delay is jittered, so it is sampled several times
+			for range 100 {
+				if delay := tc.backoff.Delay(tc.retry); delay < tc.min || delay > tc.max {
+					t.Fatalf("Delay(%d) = %v, want between %v and %v", tc.retry, delay, tc.min, tc.max)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// defaultUserCacheTTL is used when no cache time to live is configured.
+	defaultUserCacheTTL = time.Minute
+
+	// userCacheChannel is the PostgreSQL channel on which user IDs are notified once written.
+	userCacheChannel = "users_cache_invalidation"
+)
+
+// cachedUserRepository decorates a UserRepository with a cache of the users read by ID and by email
+// Concurrent misses of the same user are collapsed into a single read, and writes invalidate the users they touch.
+// Writes made in a transaction invalidate once it commits, so that a miss concurrent with the transaction cannot cache the old user for good.
+// When listening, invalidations are also received from the other replicas of the API through PostgreSQL LISTEN/NOTIFY.
+type cachedUserRepository struct {
+	next   UserRepository
+	cache  *userCache
+	group  singleflight.Group
+	logger *zerolog.Logger
+	// db and listener are only set when invalidations are fanned out through PostgreSQL
+	db       *Database
+	listener *listener
+}
+
+// newCachedUserRepository caches at most size users of next for ttl.
+func newCachedUserRepository(next UserRepository, size int, ttl time.Duration, logger *zerolog.Logger) *cachedUserRepository {
+	if ttl <= 0 {
+		ttl = defaultUserCacheTTL
+	}
+
+	return &cachedUserRepository{
+		next:   next,
+		cache:  newUserCache(size, ttl),
+		logger: logger,
+	}
+}
+
+// CacheStats returns the hit and miss counts of the cache.
+func (r *cachedUserRepository) CacheStats() CacheStats {
+	return r.cache.snapshot()
+}
+
+// cacheable reports whether a read made with ctx may be served from the cache
+// Reads of deleted users, reads forced on the primary and reads inside a transaction always go to the database.
+func cacheable(ctx context.Context) bool {
+	_, inTx := TxFromContext(ctx)
+	return !inTx && !usePrimary(ctx) && !includeDeleted(ctx)
+}
+
+// GetUserByID returns the cached user, or reads it once however many callers miss it at the same time.
+func (r *cachedUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	if !cacheable(ctx) {
+		return r.next.GetUserByID(ctx, id)
+	}
+	if user, ok := r.cache.getByID(id); ok {
+		return user, nil
+	}
+
+	return r.load(ctx, "id:"+id.String(), func(ctx context.Context) (*User, error) {
+		return r.next.GetUserByID(ctx, id)
+	})
+}
+
+// GetUserByEmail returns the cached user, or reads it once however many callers miss it at the same time.
+func (r *cachedUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if !cacheable(ctx) {
+		return r.next.GetUserByEmail(ctx, email)
+	}
+	if user, ok := r.cache.getByEmail(email); ok {
+		return user, nil
+	}
+
+	return r.load(ctx, "email:"+email, func(ctx context.Context) (*User, error) {
+		return r.next.GetUserByEmail(ctx, email)
+	})
+}
+
+// load reads a missing user through read and caches it, sharing the read between the callers of the same key
+// The read is made on the primary, so that a lagging replica never caches a user older than the last invalidation.
+// It outlives the cancellation of the caller that started it, since other callers may be waiting for it.
+// Reads are only shared within a cache generation, so that callers arriving after an invalidation never join a read started before it,
+// whether by ID, by the new email of the user, or by the email it was changed from.
+func (r *cachedUserRepository) load(ctx context.Context, key string, read func(ctx context.Context) (*User, error)) (*User, error) {
+	generation := r.cache.begin()
+	ch := r.group.DoChan(key+"@"+strconv.FormatUint(generation, 10), func() (any, error) {
+		user, err := read(WithPrimary(context.WithoutCancel(ctx)))
+		if err != nil {
+			return nil, err
+		}
+
+		r.cache.add(user, generation)
+		return user, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		// Callers sharing the read must not share the user
+		user := *res.Val.(*User)
+		return &user, nil
+	}
+}
+
+// CreateUser creates a user, and drops any cached user of the same email.
+func (r *cachedUserRepository) CreateUser(ctx context.Context, user *User) (*User, error) {
+	email := user.Email
+	created, err := r.next.CreateUser(ctx, user)
+	afterCommit(ctx, func() { r.cache.invalidateEmail(email) })
+	return created, err
+}
+
+// UpdateUser updates a user and invalidates it.
+func (r *cachedUserRepository) UpdateUser(ctx context.Context, user *User) (*User, error) {
+	id := user.ID
+	updated, err := r.next.UpdateUser(ctx, user)
+	afterCommit(ctx, func() { r.invalidate(id) })
+	if err == nil {
+		r.notify(ctx, id)
+	}
+	return updated, err
+}
+
+// PatchUser patches a user and invalidates it.
+func (r *cachedUserRepository) PatchUser(ctx context.Context, id uuid.UUID, patch UserPatch) (*User, error) {
+	patched, err := r.next.PatchUser(ctx, id, patch)
+	afterCommit(ctx, func() { r.invalidate(id) })
+	if err == nil {
+		r.notify(ctx, id)
+	}
+	return patched, err
+}
+
+// DeleteUser deletes a user and invalidates it.
+func (r *cachedUserRepository) DeleteUser(ctx context.Context, id uuid.UUID, version int64) error {
+	err := r.next.DeleteUser(ctx, id, version)
+	afterCommit(ctx, func() { r.invalidate(id) })
+	if err == nil {
+		r.notify(ctx, id)
+	}
+	return err
+}
+
+// RestoreUser restores a user and invalidates it.
+func (r *cachedUserRepository) RestoreUser(ctx context.Context, id uuid.UUID, version int64) (*User, error) {
+	restored, err := r.next.RestoreUser(ctx, id, version)
+	afterCommit(ctx, func() { r.invalidate(id) })
+	if err == nil {
+		r.notify(ctx, id)
+	}
+	return restored, err
+}
+
+// ListUsers is never cached, listings depend on too many parameters.
+func (r *cachedUserRepository) ListUsers(ctx context.Context, params ListUsersParams) (*UserPage, error) {
+	return r.next.ListUsers(ctx, params)
+}
+
+// BatchCreateUsers creates users, and drops any cached user of the same emails.
+func (r *cachedUserRepository) BatchCreateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	emails := make([]string, len(users))
+	for i, user := range users {
+		emails[i] = user.Email
+	}
+
+	results, err := r.next.BatchCreateUsers(ctx, users, atomic)
+	afterCommit(ctx, func() {
+		for _, email := range emails {
+			r.cache.invalidateEmail(email)
+		}
+	})
+	return results, err
+}
+
+// BatchUpdateUsers updates users and invalidates them.
+func (r *cachedUserRepository) BatchUpdateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+
+	results, err := r.next.BatchUpdateUsers(ctx, users, atomic)
+	afterCommit(ctx, func() { r.invalidate(ids...) })
+	if err == nil {
+		r.notify(ctx, appliedIDs(ids, results)...)
+	}
+	return results, err
+}
+
+// BatchDeleteUsers deletes users and invalidates them.
+func (r *cachedUserRepository) BatchDeleteUsers(ctx context.Context, refs []UserRef, atomic bool) ([]BatchResult, error) {
+	ids := make([]uuid.UUID, len(refs))
+	for i, ref := range refs {
+		ids[i] = ref.ID
+	}
+
+	results, err := r.next.BatchDeleteUsers(ctx, refs, atomic)
+	afterCommit(ctx, func() { r.invalidate(ids...) })
+	if err == nil {
+		r.notify(ctx, appliedIDs(ids, results)...)
+	}
+	return results, err
+}
+
+// appliedIDs returns the IDs of the batch items that succeeded.
+func appliedIDs(ids []uuid.UUID, results []BatchResult) []uuid.UUID {
+	applied := make([]uuid.UUID, 0, len(ids))
+	for i, result := range results {
+		if result.Err == nil && i < len(ids) {
+			applied = append(applied, ids[i])
+		}
+	}
+	return applied
+}
+
+// invalidate drops the cached users with the given IDs, which also detaches new readers from the reads in flight
+// The failed writes are invalidated too, since they may have been applied before failing.
+func (r *cachedUserRepository) invalidate(ids ...uuid.UUID) {
+	r.cache.invalidate(ids...)
+}
+
+// notify publishes the written user IDs to the other replicas of the API
+// Notifications are sent in the transaction of ctx, if any, and are only delivered once it commits.
+func (r *cachedUserRepository) notify(ctx context.Context, ids ...uuid.UUID) {
+	if r.db == nil || len(ids) == 0 {
+		return
+	}
+
+	query := `
+		-- name: NotifyUserCacheInvalidation
+		SELECT pg_notify($1, id) FROM unnest($2::text[]) AS id
+	`
+
+	payloads := make([]string, len(ids))
+	for i, id := range ids {
+		payloads[i] = id.String()
+	}
+
+	// The write succeeded, other replicas only serve stale users until they expire
+	if _, err := querier(ctx, r.db).Exec(ctx, query, userCacheChannel, payloads); err != nil {
+		r.logger.Warn().Err(err).Msg("Failed to notify user cache invalidation")
+	}
+}
+
+// listen starts notifying writes through db, and invalidating the users written by the other replicas.
+func (r *cachedUserRepository) listen(db *Database) {
+	r.db = db
+	// Notifications sent while disconnected are lost, so the cache is purged every time the listener connects
+	r.listener = newListener(db, userCacheChannel, r.logger, r.cache.purge, func(_ context.Context, payload string) {
+		id, err := uuid.Parse(payload)
+		if err != nil {
+			r.logger.Warn().Str("payload", payload).Msg("Ignoring invalid user cache invalidation")
+			return
+		}
+		r.invalidate(id)
+	})
+}
+
+// Shutdown stops the invalidation listener.
+func (r *cachedUserRepository) Shutdown() error {
+	if r.listener != nil {
+		r.listener.stop()
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// countingUserRepository counts the reads of the wrapped repository, optionally blocking them until released.
+type countingUserRepository struct {
+	UserRepository
+	reads   atomic.Int64
+	release chan struct{}
+}
+
+func (r *countingUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	r.reads.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.UserRepository.GetUserByID(ctx, id)
+}
+
+func (r *countingUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	r.reads.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.UserRepository.GetUserByEmail(ctx, email)
+}
+
+func newTestCachedUserRepository(t *testing.T) (*cachedUserRepository, *countingUserRepository) {
+	t.Helper()
+
+	logger := zerolog.Nop()
+	next := &countingUserRepository{UserRepository: newMemoryUserRepository()}
+	return newCachedUserRepository(next, 10, time.Minute, &logger), next
+}
+
+func TestCachedUserRepository(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, next := newTestCachedUserRepository(t)
+
+	user, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	for range 3 {
+		if _, err := repo.GetUserByID(ctx, user.ID); err != nil {
+			t.Fatalf("GetUserByID() error = %v", err)
+		}
+	}
+	if got, err := repo.GetUserByEmail(ctx, "john@example.com"); err != nil || got.ID != user.ID {
+		t.Fatalf("GetUserByEmail() = %v, %v", got, err)
+	}
+	if reads := next.reads.Load(); reads != 1 {
+		t.Errorf("reads = %d, want the user to be read once", reads)
+	}
+	if stats := repo.CacheStats(); stats.Hits != 3 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("CacheStats() = %+v, want 3 hits and 1 miss", stats)
+	}
+
+	// Returned users are copies
+	cached, _ := repo.GetUserByID(ctx, user.ID)
+	cached.Name = "Mutated"
+
+	// Writes invalidate the user, by ID and by email
+	name := "Johnny"
+	if _, err := repo.PatchUser(ctx, user.ID, UserPatch{Name: &name}); err != nil {
+		t.Fatalf("PatchUser() error = %v", err)
+	}
+	if got, _ := repo.GetUserByEmail(ctx, "john@example.com"); got.Name != "Johnny" {
+		t.Errorf("GetUserByEmail() name = %q, want the patched name", got.Name)
+	}
+
+	if err := repo.DeleteUser(ctx, user.ID, 0); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := repo.GetUserByID(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByID() error = %v, want ErrNotFound once deleted", err)
+	}
+
+	// Reads of deleted users bypass the cache
+	if got, err := repo.GetUserByID(WithDeleted(ctx), user.ID); err != nil || got.DeletedAt == nil {
+		t.Errorf("GetUserByID(WithDeleted) = %v, %v, want the deleted user", got, err)
+	}
+}
+
+func TestCachedUserRepositoryCollapsesMisses(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, next := newTestCachedUserRepository(t)
+
+	user, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	next.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.GetUserByID(ctx, user.ID); err != nil {
+				t.Errorf("GetUserByID() error = %v", err)
+			}
+		}()
+	}
+
+	// Wait for the first read to start, then for the others to join it
+	for next.reads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	if reads := next.reads.Load(); reads != 1 {
+		t.Errorf("reads = %d, want concurrent misses to share a single read", reads)
+	}
+}
+
+func TestCachedUserRepositoryDetachesReadsByEmail(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo, next := newTestCachedUserRepository(t)
+
+	user, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	// A read of the old email is in flight when the email changes
+	next.release = make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = repo.GetUserByEmail(ctx, "john@example.com")
+	}()
+	for next.reads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	email := "johnny@example.com"
+	if _, err := repo.PatchUser(ctx, user.ID, UserPatch{Email: &email}); err != nil {
+		t.Fatalf("PatchUser() error = %v", err)
+	}
+
+	// Later readers must not join the read started before the change
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = repo.GetUserByEmail(ctx, "john@example.com")
+	}()
+	deadline := time.Now().Add(time.Second)
+	for next.reads.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(next.release)
+	wg.Wait()
+
+	if reads := next.reads.Load(); reads != 2 {
+		t.Errorf("reads = %d, want the read of the old email to be detached from later readers", reads)
+	}
+}
+
+// txUserRepository only applies the updates made in a transaction once it commits, like PostgreSQL hides them from other connections.
+type txUserRepository struct {
+	UserRepository
+}
+
+func (r *txUserRepository) UpdateUser(ctx context.Context, user *User) (*User, error) {
+	updated := *user
+	afterCommit(ctx, func() { _, _ = r.UserRepository.UpdateUser(context.Background(), &updated) })
+	return user, nil
+}
+
+func TestCachedUserRepositoryInvalidatesAfterCommit(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	repo := newCachedUserRepository(&txUserRepository{UserRepository: newMemoryUserRepository()}, 10, time.Minute, &logger)
+	manager, _ := newFakeTxManager(0)
+
+	user, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	err = manager.WithinTx(ctx, func(txCtx context.Context) error {
+		update := *user
+		update.Name = "Johnny"
+		if _, err := repo.UpdateUser(txCtx, &update); err != nil {
+			return err
+		}
+
+		// A concurrent request misses the user before the transaction commits, and caches the old one
+		if got, err := repo.GetUserByID(ctx, user.ID); err != nil || got.Name != "John" {
+			t.Errorf("GetUserByID() = %v, %v, want the uncommitted update to be invisible", got, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx() error = %v", err)
+	}
+
+	if got, err := repo.GetUserByID(ctx, user.ID); err != nil || got.Name != "Johnny" {
+		t.Errorf("GetUserByID() = %v, %v, want the committed update", got, err)
+	}
+}
+
+func TestUserCache(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newUserCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	users := make([]*User, 3)
+	for i := range users {
+		users[i] = &User{ID: uuid.New(), Email: uuid.NewString() + "@example.com"}
+	}
+
+	// The least recently used user is evicted
+	cache.add(users[0], cache.begin())
+	cache.add(users[1], cache.begin())
+	cache.getByID(users[0].ID)
+	cache.add(users[2], cache.begin())
+	if _, ok := cache.getByID(users[1].ID); ok {
+		t.Error("getByID() found the least recently used user")
+	}
+	if _, ok := cache.getByEmail(users[0].Email); !ok {
+		t.Error("getByEmail() did not find a recently used user")
+	}
+	if stats := cache.snapshot(); stats.Evictions != 1 || stats.Size != 2 {
+		t.Errorf("snapshot() = %+v, want 1 eviction", stats)
+	}
+
+	// Users expire after the TTL
+	now = now.Add(time.Minute + time.Second)
+	if _, ok := cache.getByID(users[2].ID); ok {
+		t.Error("getByID() found an expired user")
+	}
+
+	// Users loaded before an invalidation are not cached
+	generation := cache.begin()
+	cache.invalidate(users[1].ID)
+	cache.add(users[1], generation)
+	if _, ok := cache.getByID(users[1].ID); ok {
+		t.Error("getByID() found a user loaded before its invalidation")
+	}
+}
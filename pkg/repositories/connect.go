@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// ErrDatabaseNotReady is returned by health checks until the first connection succeeds.
+var ErrDatabaseNotReady = errors.New("database is not ready")
+
+// connectPolicy controls how the primary connection is retried at startup.
+type connectPolicy struct {
+	Backoff
+	// retries is the number of attempts after the first one, a negative value retries forever
+	retries int
+	timeout time.Duration
+}
+
+// connect pings the pool until it answers, following the retry policy.
+func connect(ctx context.Context, pool *pgxpool.Pool, policy connectPolicy, logger *zerolog.Logger) error {
+	if policy.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.timeout)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := pool.Ping(ctx)
+		if err == nil {
+			if attempt > 1 {
+				logger.Info().Int("attempt", attempt).Msg("Connected to database")
+			}
+			return nil
+		}
+
+		if policy.retries >= 0 && attempt > policy.retries {
+			return fmt.Errorf("failed to ping database after %d attempt(s): %w", attempt, err)
+		}
+
+		delay := policy.Delay(attempt)
+		logger.Warn().Err(err).Int("attempt", attempt).Dur("retry_in", delay).Msg("Database is not reachable yet, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to ping database after %d attempt(s): %w", attempt, errors.Join(ctx.Err(), err))
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+func TestConnect(t *testing.T) {
+	t.Parallel()
+
+	logger := zerolog.Nop()
+
+	testCases := map[string]struct {
+		policy connectPolicy
+		// cancel cancels the context of connect after the given delay, when set
+		cancel  time.Duration
+		wantErr error
+		want    string
+	}{
+		"retries exhausted": {policy: connectPolicy{Backoff: Backoff{Initial: time.Millisecond}, retries: 2}, want: "after 3 attempt(s)"},
+		"cancelled":         {policy: connectPolicy{Backoff: Backoff{Initial: time.Hour}, retries: -1}, cancel: 50 * time.Millisecond, wantErr: context.Canceled},
+		"timed out":         {policy: connectPolicy{Backoff: Backoff{Initial: time.Hour}, retries: -1, timeout: 50 * time.Millisecond}, wantErr: context.DeadlineExceeded},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Nothing listens on the port, so every ping fails right away
+			pool, err := pgxpool.New(context.Background(), "postgres://user@127.0.0.1:1/db?connect_timeout=1")
+			if err != nil {
+				t.Fatalf("failed to create pool: %v", err)
+			}
+			defer pool.Close()
+
+			ctx := context.Background()
+			if tc.cancel > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithCancel(ctx)
+				defer cancel()
+				time.AfterFunc(tc.cancel, cancel)
+			}
+
+			start := time.Now()
+			err = connect(ctx, pool, tc.policy, &logger)
+			if err == nil {
+				t.Fatal("connect() succeeded, want it to give up")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("connect() error = %v, want %v", err, tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("connect() error = %v, want %q", err, tc.want)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("connect() gave up after %v, want it to stop waiting for the next retry", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/samber/do-template-api/pkg/config"
+)
+
+// Database drivers selectable with `database.driver`.
+const (
+	// DriverPostgres stores data in PostgreSQL, the default.
+	DriverPostgres = "postgres"
+	// DriverMemory stores data in memory, for demos and tests. Data is lost on restart.
+	DriverMemory = "memory"
+	// DriverSQLite stores data in the embedded SQLite database at `database.path`, for single-node deployments.
+	DriverSQLite = "sqlite"
+)
+
+// ErrUnsupportedDriver is returned by the services which the configured database driver does not provide
+// Callers check it with errors.Is to tell an optional feature from a failure.
+var ErrUnsupportedDriver = errors.New("unsupported by the database driver")
+
+// databaseDriver returns the configured database driver, PostgreSQL when none is set.
+func databaseDriver(cfg *config.Config) (string, error) {
+	switch cfg.Database.Driver {
+	case "", DriverPostgres:
+		return DriverPostgres, nil
+	case DriverMemory, DriverSQLite:
+		return cfg.Database.Driver, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q, expected %s, %s or %s", cfg.Database.Driver, DriverPostgres, DriverSQLite, DriverMemory)
+	}
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sentinel errors returned by repositories
+// Callers check them with errors.Is, whatever the underlying storage.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+	ErrInvalid  = errors.New("invalid")
+	// ErrPreconditionFailed is returned by conditional writes when the stored version moved.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrAborted is returned for the items of an all-or-nothing batch rolled back because of another item.
+	ErrAborted = errors.New("aborted")
+)
+
+// PostgreSQL error codes translated into sentinel errors.
+const (
+	pgUniqueViolation     = "23505"
+	pgNotNullViolation    = "23502"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+	pgInvalidText         = "22P02"
+)
+
+// constraintDetails describes unique constraint violations in client-safe terms.
+var constraintDetails = map[string]string{
+	"users_email_key": "email already exists",
+}
+
+// Error is a repository error wrapping a sentinel error with a client-safe detail.
+type Error struct {
+	kind   error
+	detail string
+	cause  error
+}
+
+// newError creates a repository error of the given kind.
+func newError(kind error, detail string, cause error) *Error {
+	return &Error{kind: kind, detail: detail, cause: cause}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.detail + ": " + e.cause.Error()
+	}
+	return e.detail
+}
+
+// Unwrap exposes both the sentinel error and the cause to errors.Is and errors.As.
+func (e *Error) Unwrap() []error {
+	if e.cause != nil {
+		return []error{e.kind, e.cause}
+	}
+	return []error{e.kind}
+}
+
+// Detail returns a message describing the error that is safe to show to clients.
+func (e *Error) Detail() string {
+	return e.detail
+}
+
+// ErrorDetail returns the client-safe detail of a repository error, or an empty string.
+func ErrorDetail(err error) string {
+	var repoErr *Error
+	if errors.As(err, &repoErr) {
+		return repoErr.Detail()
+	}
+	return ""
+}
+
+// translateError converts pgx and PostgreSQL errors about resource into sentinel errors.
+// Other errors are returned unchanged.
+func translateError(err error, resource string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return newError(ErrNotFound, resource+" not found", err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		detail, ok := constraintDetails[pgErr.ConstraintName]
+		if !ok {
+			detail = resource + " already exists"
+		}
+		return newError(ErrConflict, detail, err)
+	case pgNotNullViolation, pgForeignKeyViolation, pgCheckViolation, pgStringTooLong, pgInvalidText:
+		detail := "invalid " + resource
+		if pgErr.ColumnName != "" {
+			detail = "invalid " + resource + " " + pgErr.ColumnName
+		}
+		return newError(ErrInvalid, detail, err)
+	default:
+		return err
+	}
+}
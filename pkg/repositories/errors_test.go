@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslateError(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err    error
+		kind   error
+		detail string
+	}{
+		"no rows": {
+			err:    pgx.ErrNoRows,
+			kind:   ErrNotFound,
+			detail: "user not found",
+		},
+		"unique email": {
+			err:    &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_email_key"},
+			kind:   ErrConflict,
+			detail: "email already exists",
+		},
+		"unique other": {
+			err:    &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_pkey"},
+			kind:   ErrConflict,
+			detail: "user already exists",
+		},
+		"too long": {
+			err:    &pgconn.PgError{Code: pgStringTooLong},
+			kind:   ErrInvalid,
+			detail: "invalid user",
+		},
+		"not null": {
+			err:    &pgconn.PgError{Code: pgNotNullViolation, ColumnName: "name"},
+			kind:   ErrInvalid,
+			detail: "invalid user name",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := fmt.Errorf("failed: %w", translateError(tc.err, "user"))
+			if !errors.Is(err, tc.kind) {
+				t.Errorf("expected %v, got %v", tc.kind, err)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected the cause to be kept, got %v", err)
+			}
+			if detail := ErrorDetail(err); detail != tc.detail {
+				t.Errorf("expected detail %q, got %q", tc.detail, detail)
+			}
+		})
+	}
+
+	other := &pgconn.PgError{Code: "57014"}
+	if err := translateError(other, "user"); err != other {
+		t.Errorf("expected unknown errors to be returned unchanged, got %v", err)
+	}
+}
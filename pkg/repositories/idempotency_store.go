@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// idempotencyLockTimeout is the time after which an in-flight request is considered abandoned, e.g. by a crashed instance.
+	idempotencyLockTimeout = time.Minute
+
+	// idempotencyPurgeInterval is the delay between two purges of the expired keys.
+	idempotencyPurgeInterval = 10 * time.Minute
+
+	// defaultIdempotencyTTL is used when no retention is configured.
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+// IdempotencyRecord is the state of a request stored by its Idempotency-Key.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	// StatusCode is zero while the first request is in flight.
+	StatusCode int
+	Header     map[string][]string
+	Body       []byte
+	ExpiresAt  time.Time
+}
+
+// Completed reports whether the response of the request was stored.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// IdempotencyStore stores the responses of mutating requests by Idempotency-Key
+// This interface lets the HTTP layer replay responses whatever the storage.
+type IdempotencyStore interface {
+	// Begin reserves key for a request. It returns the time of the reservation when the key was reserved,
+	// and the record of the request that holds the key otherwise.
+	Begin(ctx context.Context, key, fingerprint string) (time.Time, *IdempotencyRecord, error)
+	// Complete stores the response of the request holding key since reservedAt.
+	// It does nothing once the key was taken over by a retry.
+	Complete(ctx context.Context, key string, reservedAt time.Time, statusCode int, header map[string][]string, body []byte) error
+	// Release frees key, so that the request can be retried.
+	// It does nothing once the key was taken over by a retry.
+	Release(ctx context.Context, key string, reservedAt time.Time) error
+}
+
+// idempotencyStore implements IdempotencyStore on the idempotency_keys table
+// Expired keys are purged in the background.
+type idempotencyStore struct {
+	db     *Database       `do:""`
+	logger *zerolog.Logger `do:""`
+	ttl    time.Duration
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewIdempotencyStore creates a new IdempotencyStore keeping responses for `server.idempotency_ttl` seconds
+// This function demonstrates how to initialize a service running a background task.
+// Responses are kept in memory by the drivers other than PostgreSQL, which run on a single node.
+func NewIdempotencyStore(injector do.Injector) (IdempotencyStore, error) {
+	cfg := do.MustInvoke[*config.Config](injector)
+
+	ttl := time.Duration(cfg.Server.IdempotencyTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	driver, err := databaseDriver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if driver != DriverPostgres {
+		return newMemoryIdempotencyStore(ttl), nil
+	}
+
+	store := do.MustInvokeStruct[*idempotencyStore](injector)
+	store.ttl = ttl
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store.cancel = cancel
+
+	store.wg.Add(1)
+	go func() {
+		defer store.wg.Done()
+
+		ticker := time.NewTicker(idempotencyPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := store.purge(ctx); err != nil && ctx.Err() == nil {
+					store.logger.Warn().Err(err).Msg("Failed to purge expired idempotency keys")
+				}
+			}
+		}
+	}()
+
+	return store, nil
+}
+
+// Begin implements IdempotencyStore.
+func (s *idempotencyStore) Begin(ctx context.Context, key, fingerprint string) (time.Time, *IdempotencyRecord, error) {
+	// Expired and abandoned keys are taken over, live ones are left untouched
+	// created_at identifies the reservation, so that an abandoned request no longer updates the key once taken over
+	query := `
+		-- name: BeginIdempotentRequest
+		INSERT INTO idempotency_keys (key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, now(), now() + $3::INTERVAL)
+		ON CONFLICT (key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at,
+			status_code = NULL, response_headers = NULL, response_body = NULL
+		WHERE idempotency_keys.expires_at < now()
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < now() - $4::INTERVAL)
+		RETURNING created_at
+	`
+
+	var reservedAt time.Time
+	err := querier(ctx, s.db).QueryRow(ctx, query, key, fingerprint, s.ttl, idempotencyLockTimeout).Scan(&reservedAt)
+	if err == nil {
+		return reservedAt, nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil, fmt.Errorf("failed to reserve idempotency key: %w", translateError(err, "idempotency key"))
+	}
+
+	query = `
+		-- name: GetIdempotentRequest
+		SELECT key, fingerprint, COALESCE(status_code, 0), response_headers, response_body, expires_at
+		FROM idempotency_keys
+		WHERE key = $1
+	`
+
+	var record IdempotencyRecord
+	err = querier(ctx, s.db).QueryRow(ctx, query, key).Scan(
+		&record.Key, &record.Fingerprint, &record.StatusCode, &record.Header, &record.Body, &record.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The key was purged in between, try again
+		return s.Begin(ctx, key, fingerprint)
+	}
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("failed to get idempotency key: %w", translateError(err, "idempotency key"))
+	}
+
+	return time.Time{}, &record, nil
+}
+
+// Complete implements IdempotencyStore.
+func (s *idempotencyStore) Complete(ctx context.Context, key string, reservedAt time.Time, statusCode int, header map[string][]string, body []byte) error {
+	query := `
+		-- name: CompleteIdempotentRequest
+		UPDATE idempotency_keys
+		SET status_code = $3, response_headers = $4, response_body = $5
+		WHERE key = $1 AND created_at = $2
+	`
+
+	if _, err := querier(ctx, s.db).Exec(ctx, query, key, reservedAt, statusCode, header, body); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", translateError(err, "idempotency key"))
+	}
+
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (s *idempotencyStore) Release(ctx context.Context, key string, reservedAt time.Time) error {
+	query := `
+		-- name: ReleaseIdempotencyKey
+		DELETE FROM idempotency_keys WHERE key = $1 AND created_at = $2 AND status_code IS NULL
+	`
+
+	if _, err := querier(ctx, s.db).Exec(ctx, query, key, reservedAt); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", translateError(err, "idempotency key"))
+	}
+
+	return nil
+}
+
+// purge deletes the expired keys.
+func (s *idempotencyStore) purge(ctx context.Context) error {
+	query := `
+		-- name: PurgeIdempotencyKeys
+		DELETE FROM idempotency_keys WHERE expires_at < now()
+	`
+
+	result, err := querier(ctx, s.db).Exec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+
+	s.logger.Debug().Int64("keys", result.RowsAffected()).Msg("Purged expired idempotency keys")
+	return nil
+}
+
+// Shutdown stops purging expired keys.
+func (s *idempotencyStore) Shutdown() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
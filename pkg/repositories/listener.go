@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+// listenBackoff controls how the listeners reconnect.
+var listenBackoff = Backoff{Initial: 500 * time.Millisecond, Max: 10 * time.Second}
+
+// listener receives the notifications of a PostgreSQL channel on a dedicated connection, reconnecting until it is stopped
+// It is shared by the user cache invalidations and the user event stream.
+type listener struct {
+	db      *Database
+	channel string
+	logger  *zerolog.Logger
+	// onListen is called every time the listener starts listening, since the notifications sent while disconnected are lost
+	onListen func()
+	// onNotify is called with the payload of every notification
+	onNotify func(ctx context.Context, payload string)
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// newListener starts listening to channel in the background.
+func newListener(db *Database, channel string, logger *zerolog.Logger, onListen func(), onNotify func(ctx context.Context, payload string)) *listener {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l := &listener{
+		db:       db,
+		channel:  channel,
+		logger:   logger,
+		onListen: onListen,
+		onNotify: onNotify,
+		cancel:   cancel,
+	}
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		for attempt := 1; ; attempt++ {
+			listened, err := l.listenOnce(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if listened {
+				attempt = 1
+			}
+
+			delay := listenBackoff.Delay(attempt)
+			l.logger.Warn().Err(err).Str("channel", l.channel).Dur("retry_in", delay).Msg("Listener disconnected, reconnecting")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+	}()
+
+	return l
+}
+
+// listenOnce listens on a dedicated connection until it fails, and reports whether it was listening.
+func (l *listener) listenOnce(ctx context.Context) (bool, error) {
+	// Wait for the database to come up when connecting lazily
+	if !l.db.Ready() {
+		return false, ErrDatabaseNotReady
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, l.db.Pool().Config().ConnConfig)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return false, err
+	}
+
+	l.onListen()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		l.onNotify(ctx, notification.Payload)
+	}
+}
+
+// stop stops listening, and waits for the notification in progress to be handled.
+func (l *listener) stop() {
+	l.cancel()
+	l.wg.Wait()
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestListenerWaitsForDatabase(t *testing.T) {
+	t.Parallel()
+
+	logger := zerolog.Nop()
+	listened := make(chan struct{})
+
+	// The database never becomes ready, so the listener keeps waiting for it until stopped
+	l := newListener(&Database{}, userEventChannel, &logger, func() { close(listened) }, func(context.Context, string) {})
+
+	select {
+	case <-listened:
+		t.Fatal("listener listened before the database was ready")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		l.stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop() did not interrupt the reconnection delay")
+	}
+}
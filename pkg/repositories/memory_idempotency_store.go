@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// memoryIdempotencyStore implements IdempotencyStore in memory
+// Expired keys are purged while reserving new ones, at most once per purge interval.
+type memoryIdempotencyStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	records    map[string]*memoryIdempotencyRecord
+	lastPurged time.Time
+}
+
+// memoryIdempotencyRecord is a stored record with its reservation time, to take over abandoned keys
+// and to ignore the abandoned requests once their key was taken over.
+type memoryIdempotencyRecord struct {
+	IdempotencyRecord
+	createdAt time.Time
+}
+
+// newMemoryIdempotencyStore creates an empty in-memory IdempotencyStore keeping responses for ttl.
+func newMemoryIdempotencyStore(ttl time.Duration) *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		ttl:        ttl,
+		records:    map[string]*memoryIdempotencyRecord{},
+		lastPurged: time.Now(),
+	}
+}
+
+// Begin implements IdempotencyStore.
+func (s *memoryIdempotencyStore) Begin(_ context.Context, key, fingerprint string) (time.Time, *IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPurged) >= idempotencyPurgeInterval {
+		maps.DeleteFunc(s.records, func(_ string, record *memoryIdempotencyRecord) bool {
+			return record.ExpiresAt.Before(now)
+		})
+		s.lastPurged = now
+	}
+
+	// Expired and abandoned keys are taken over, live ones are left untouched
+	record, ok := s.records[key]
+	if ok && !record.ExpiresAt.Before(now) && (record.Completed() || now.Sub(record.createdAt) <= idempotencyLockTimeout) {
+		held := record.IdempotencyRecord
+		return time.Time{}, &held, nil
+	}
+
+	s.records[key] = &memoryIdempotencyRecord{
+		IdempotencyRecord: IdempotencyRecord{Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(s.ttl)},
+		createdAt:         now,
+	}
+	return now, nil, nil
+}
+
+// Complete implements IdempotencyStore.
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key string, reservedAt time.Time, statusCode int, header map[string][]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.createdAt.Equal(reservedAt) {
+		record.StatusCode, record.Header, record.Body = statusCode, header, body
+	}
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (s *memoryIdempotencyStore) Release(_ context.Context, key string, reservedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.createdAt.Equal(reservedAt) && !record.Completed() {
+		delete(s.records, key)
+	}
+	return nil
+}
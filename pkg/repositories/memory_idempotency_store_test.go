@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryIdempotencyStoreTakeover(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := newMemoryIdempotencyStore(time.Hour)
+
+	abandonedAt, record, err := store.Begin(ctx, "key", "fingerprint")
+	if err != nil || record != nil {
+		t.Fatalf("Begin() = %v, %v, want a reservation", record, err)
+	}
+
+	// The first request is considered abandoned, and a retry takes the key over
+	store.records["key"].createdAt = abandonedAt.Add(-2 * idempotencyLockTimeout)
+	abandonedAt = store.records["key"].createdAt
+
+	reservedAt, record, err := store.Begin(ctx, "key", "fingerprint")
+	if err != nil || record != nil {
+		t.Fatalf("Begin() = %v, %v, want the abandoned key to be taken over", record, err)
+	}
+
+	// The abandoned request completes or fails late, the retry must keep the key
+	if err := store.Complete(ctx, "key", abandonedAt, 201, nil, []byte("abandoned")); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := store.Release(ctx, "key", abandonedAt); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, record, _ := store.Begin(ctx, "key", "fingerprint"); record == nil || record.Completed() {
+		t.Fatalf("Begin() = %v, want the retry to still hold the key", record)
+	}
+
+	if err := store.Complete(ctx, "key", reservedAt, 201, nil, []byte("retry")); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if _, record, _ := store.Begin(ctx, "key", "fingerprint"); record == nil || string(record.Body) != "retry" {
+		t.Fatalf("Begin() = %v, want the response of the retry", record)
+	}
+}
@@ -0,0 +1,460 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// trigramSimilarityThreshold is the default threshold of the pg_trgm `%` operator.
+const trigramSimilarityThreshold = 0.3
+
+// memoryUserRepository implements the UserRepository interface in memory
+// It follows the semantics of the PostgreSQL implementation: unique emails among live users, sort orders, cursors and soft deletes.
+// Transactions are not supported, every write is applied immediately.
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]User
+}
+
+// newMemoryUserRepository creates an empty in-memory UserRepository.
+func newMemoryUserRepository() *memoryUserRepository {
+	return &memoryUserRepository{users: map[uuid.UUID]User{}}
+}
+
+// memoryNow returns the current time at the microsecond precision of PostgreSQL timestamps.
+func memoryNow() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// CreateUser implements UserRepository.
+func (r *memoryUserRepository) CreateUser(_ context.Context, user *User) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return createMemoryUser(r.users, user, memoryNow())
+}
+
+// GetUserByID implements UserRepository.
+func (r *memoryUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok || (user.DeletedAt != nil && !includeDeleted(ctx)) {
+		return nil, newError(ErrNotFound, "user not found", nil)
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmail implements UserRepository.
+func (r *memoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email && (user.DeletedAt == nil || includeDeleted(ctx)) {
+			return &user, nil
+		}
+	}
+
+	return nil, newError(ErrNotFound, "user not found", nil)
+}
+
+// UpdateUser implements UserRepository.
+func (r *memoryUserRepository) UpdateUser(_ context.Context, user *User) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	updated, err := updateMemoryUser(r.users, user, memoryNow())
+	if err != nil {
+		return nil, err
+	}
+
+	*user = *updated
+	return user, nil
+}
+
+// PatchUser implements UserRepository.
+func (r *memoryUserRepository) PatchUser(_ context.Context, id uuid.UUID, patch UserPatch) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, err := liveMemoryUser(r.users, id, patch.Version)
+	if err != nil || patch.IsZero() {
+		return user, err
+	}
+
+	if patch.Name != nil {
+		user.Name = *patch.Name
+	}
+	if patch.Email != nil {
+		if err := checkMemoryEmail(r.users, id, *patch.Email); err != nil {
+			return nil, err
+		}
+		user.Email = *patch.Email
+	}
+	user.UpdatedAt = memoryNow()
+	user.Version++
+
+	r.users[id] = *user
+	return user, nil
+}
+
+// DeleteUser implements UserRepository.
+func (r *memoryUserRepository) DeleteUser(_ context.Context, id uuid.UUID, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := deleteMemoryUser(r.users, UserRef{ID: id, Version: version}, memoryNow())
+	return err
+}
+
+// RestoreUser implements UserRepository.
+func (r *memoryUserRepository) RestoreUser(_ context.Context, id uuid.UUID, version int64) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	switch {
+	case !ok:
+		return nil, newError(ErrNotFound, "user not found", nil)
+	case user.DeletedAt == nil:
+		return nil, newError(ErrConflict, "user is not deleted", nil)
+	case version != 0 && user.Version != version:
+		return nil, newError(ErrPreconditionFailed, "user has been modified since it was read", nil)
+	}
+
+	if err := checkMemoryEmail(r.users, id, user.Email); err != nil {
+		return nil, err
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = memoryNow()
+	user.Version++
+
+	r.users[id] = user
+	return &user, nil
+}
+
+// ListUsers implements UserRepository.
+func (r *memoryUserRepository) ListUsers(ctx context.Context, params ListUsersParams) (*UserPage, error) {
+	sort := params.Sort
+	if len(sort) == 0 {
+		sort = DefaultUserSort
+	}
+	backward := params.Cursor != nil && params.Cursor.Backward
+
+	var after *User
+	if params.Cursor != nil {
+		user, err := cursorUser(sort, params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = user
+	}
+
+	r.mu.RLock()
+	var matching []*User
+	for _, user := range r.users {
+		if params.Filter.matches(&user) && (user.DeletedAt == nil || includeDeleted(ctx)) {
+			matching = append(matching, &user)
+		}
+	}
+	r.mu.RUnlock()
+
+	// Like the SQL query, previous pages are read in reverse order
+	compare := func(a, b *User) int {
+		c := compareUsers(sort, a, b)
+		if backward {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(matching, compare)
+
+	total := int64(len(matching))
+	if after != nil {
+		start, _ := slices.BinarySearchFunc(matching, after, func(user, after *User) int {
+			if compare(user, after) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		matching = matching[start:]
+	}
+
+	// One extra user tells whether another page follows
+	users := matching[:min(len(matching), max(params.Limit, 0)+1)]
+
+	page := &UserPage{}
+	users, next, prev := paginate(users, params.Limit, backward, params.Cursor != nil)
+	page.Users = users
+	if next != nil {
+		page.NextCursor = userCursor(*next, sort, false)
+	}
+	if prev != nil {
+		page.PrevCursor = userCursor(*prev, sort, true)
+	}
+
+	// Counts are always exact, there are no planner estimates in memory
+	if params.Total != TotalNone {
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// BatchCreateUsers implements UserRepository.
+func (r *memoryUserRepository) BatchCreateUsers(_ context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	now := memoryNow()
+	return r.runBatch(len(users), atomic, func(stored map[uuid.UUID]User, i int) (*User, error) {
+		return createMemoryUser(stored, users[i], now)
+	}), nil
+}
+
+// BatchUpdateUsers implements UserRepository.
+func (r *memoryUserRepository) BatchUpdateUsers(_ context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	now := memoryNow()
+	return r.runBatch(len(users), atomic, func(stored map[uuid.UUID]User, i int) (*User, error) {
+		return updateMemoryUser(stored, users[i], now)
+	}), nil
+}
+
+// BatchDeleteUsers implements UserRepository.
+func (r *memoryUserRepository) BatchDeleteUsers(_ context.Context, refs []UserRef, atomic bool) ([]BatchResult, error) {
+	now := memoryNow()
+	return r.runBatch(len(refs), atomic, func(stored map[uuid.UUID]User, i int) (*User, error) {
+		return deleteMemoryUser(stored, refs[i], now)
+	}), nil
+}
+
+// runBatch applies one write per item to the stored users
+// In atomic mode, the writes are applied to a copy that is only kept when every item succeeded, and the other items fail with ErrAborted otherwise.
+func (r *memoryUserRepository) runBatch(n int, atomic bool, apply func(stored map[uuid.UUID]User, i int) (*User, error)) []BatchResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.users
+	if atomic {
+		stored = maps.Clone(r.users)
+	}
+
+	results := make([]BatchResult, n)
+	failed := false
+	for i := range results {
+		user, err := apply(stored, i)
+		results[i] = BatchResult{User: user, Err: err}
+		failed = failed || err != nil
+	}
+
+	if atomic && failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BatchResult{Err: errBatchAborted}
+			}
+		}
+		return results
+	}
+
+	r.users = stored
+	return results
+}
+
+// createMemoryUser stores user with a new ID, failing with ErrConflict when its email is taken by a live user.
+func createMemoryUser(stored map[uuid.UUID]User, user *User, now time.Time) (*User, error) {
+	if err := checkMemoryEmail(stored, uuid.Nil, user.Email); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user ID: %w", err)
+	}
+
+	user.ID, user.CreatedAt, user.UpdatedAt, user.Version, user.DeletedAt = id, now, now, 1, nil
+	stored[id] = *user
+	return user, nil
+}
+
+// updateMemoryUser replaces the name and email of a live user, at user.Version when it is set.
+func updateMemoryUser(stored map[uuid.UUID]User, user *User, now time.Time) (*User, error) {
+	current, err := liveMemoryUser(stored, user.ID, user.Version)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMemoryEmail(stored, user.ID, user.Email); err != nil {
+		return nil, err
+	}
+
+	current.Name, current.Email, current.UpdatedAt = user.Name, user.Email, now
+	current.Version++
+
+	stored[user.ID] = *current
+	return current, nil
+}
+
+// deleteMemoryUser soft-deletes a live user, at ref.Version when it is set.
+func deleteMemoryUser(stored map[uuid.UUID]User, ref UserRef, now time.Time) (*User, error) {
+	user, err := liveMemoryUser(stored, ref.ID, ref.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	user.DeletedAt, user.UpdatedAt = &now, now
+	user.Version++
+
+	stored[ref.ID] = *user
+	return user, nil
+}
+
+// liveMemoryUser returns a copy of a user that is not deleted, failing with ErrPreconditionFailed when version is set and does not match.
+func liveMemoryUser(stored map[uuid.UUID]User, id uuid.UUID, version int64) (*User, error) {
+	user, ok := stored[id]
+	if !ok || user.DeletedAt != nil {
+		return nil, newError(ErrNotFound, "user not found", nil)
+	}
+	if version != 0 && user.Version != version {
+		return nil, newError(ErrPreconditionFailed, "user has been modified since it was read", nil)
+	}
+
+	return &user, nil
+}
+
+// checkMemoryEmail fails with ErrConflict when email belongs to a live user other than id, like the users_email_key index.
+func checkMemoryEmail(stored map[uuid.UUID]User, id uuid.UUID, email string) error {
+	for _, user := range stored {
+		if user.ID != id && user.Email == email && user.DeletedAt == nil {
+			return newError(ErrConflict, constraintDetails["users_email_key"], nil)
+		}
+	}
+	return nil
+}
+
+// matches reports whether user matches the filter, like the conditions of the SQL query.
+func (f UserFilter) matches(user *User) bool {
+	name, email := strings.ToLower(user.Name), strings.ToLower(user.Email)
+
+	if f.NamePrefix != "" && !strings.HasPrefix(name, strings.ToLower(f.NamePrefix)) {
+		return false
+	}
+	if f.EmailDomain != "" && !strings.HasSuffix(email, "@"+strings.ToLower(strings.TrimPrefix(f.EmailDomain, "@"))) {
+		return false
+	}
+
+	if f.CreatedAfter != nil && user.CreatedAt.Before(*f.CreatedAfter) ||
+		f.CreatedBefore != nil && !user.CreatedAt.Before(*f.CreatedBefore) ||
+		f.UpdatedAfter != nil && user.UpdatedAt.Before(*f.UpdatedAfter) ||
+		f.UpdatedBefore != nil && !user.UpdatedAt.Before(*f.UpdatedBefore) {
+		return false
+	}
+
+	if f.Query != "" {
+		query := strings.ToLower(f.Query)
+		if !strings.Contains(name, query) && !strings.Contains(email, query) && trigramSimilarity(user.Name, f.Query) < trigramSimilarityThreshold {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareUsers compares users in the sort order, the ID breaking ties in the direction of the last field like orderByClause.
+func compareUsers(sort []UserSort, a, b *User) int {
+	for _, s := range sort {
+		var c int
+		switch s.Field {
+		case UserSortName:
+			c = strings.Compare(a.Name, b.Name)
+		case UserSortEmail:
+			c = strings.Compare(a.Email, b.Email)
+		case UserSortCreatedAt:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case UserSortUpdatedAt:
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		}
+
+		if c != 0 {
+			if s.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+
+	c := bytes.Compare(a.ID[:], b.ID[:])
+	if sort[len(sort)-1].Desc {
+		return -c
+	}
+	return c
+}
+
+// cursorUser returns a user holding the sort values of cursor, to compare users with it.
+func cursorUser(sort []UserSort, cursor *Cursor) (*User, error) {
+	if cursor.Sort != FormatUserSort(sort) || len(cursor.Values) != len(sort) {
+		return nil, newError(ErrInvalid, "pagination cursor does not match the sort order", nil)
+	}
+
+	user := &User{ID: cursor.ID}
+	for i, s := range sort {
+		value, err := parseSortValue(s.Field, cursor.Values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		switch s.Field {
+		case UserSortName:
+			user.Name = value.(string)
+		case UserSortEmail:
+			user.Email = value.(string)
+		case UserSortCreatedAt:
+			user.CreatedAt = value.(time.Time)
+		case UserSortUpdatedAt:
+			user.UpdatedAt = value.(time.Time)
+		}
+	}
+
+	return user, nil
+}
+
+// trigramSimilarity returns the similarity of two strings like the pg_trgm `similarity` function:
+// the number of trigrams they share divided by the number of distinct trigrams of both.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range ta {
+		if _, ok := tb[trigram]; ok {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the trigrams of the lowercased words of s, each word being padded with two spaces before and one after.
+func trigrams(s string) map[string]struct{} {
+	set := map[string]struct{}{}
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import "testing"
+
+func TestTrigramSimilarity(t *testing.T) {
+	t.Parallel()
+
+	if got := trigramSimilarity("word", "two words"); got < 0.36 || got > 0.37 {
+		t.Errorf("trigramSimilarity() = %v, want 0.363636 like pg_trgm", got)
+	}
+	if got := trigramSimilarity("Johnn", "John"); got < trigramSimilarityThreshold {
+		t.Errorf("trigramSimilarity() = %v, want a typo to be similar", got)
+	}
+	if got := trigramSimilarity("John", "Alice"); got >= trigramSimilarityThreshold {
+		t.Errorf("trigramSimilarity() = %v, want different names not to be similar", got)
+	}
+}
@@ -0,0 +1,533 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/migrations"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+const (
+	migrationKindSingle = "single"
+	migrationKindUp     = "up"
+	migrationKindDown   = "down"
+
+	// migrationDownMarker separates the up and down sections of a single-file migration.
+	migrationDownMarker = "-- +migrate down"
+
+	// migrationLockKey identifies the advisory lock held while migrating.
+	migrationLockKey int64 = 0x646f5f6d69677261 // "do_migra"
+)
+
+// migrationNameSanitizer matches the characters replaced in new migration names.
+var migrationNameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ErrMigrationDrift is returned when an applied migration file has been modified afterward.
+var ErrMigrationDrift = errors.New("migration drift detected")
+
+// Migration represents a versioned schema change
+// This struct demonstrates how to model migrations loaded from an embedded filesystem.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus describes whether a migration has been applied to the database.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	Drifted   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded SQL migrations through the injected Database
+// This service demonstrates how to build tooling on top of other services using samber/do.
+type Migrator struct {
+	db         *Database       `do:""`
+	logger     *zerolog.Logger `do:""`
+	migrations []Migration
+}
+
+// NewMigrator creates a new Migrator loaded with the embedded migrations
+// This function demonstrates how to combine struct injection with extra initialization.
+func NewMigrator(injector do.Injector) (*Migrator, error) {
+	driver, err := databaseDriver(do.MustInvoke[*config.Config](injector))
+	if err != nil {
+		return nil, err
+	}
+	if driver != DriverPostgres {
+		return nil, fmt.Errorf("the %s database driver has no migrations", driver)
+	}
+
+	migrator := do.MustInvokeStruct[*Migrator](injector)
+
+	list, err := loadMigrations(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator.migrations = list
+	return migrator, nil
+}
+
+// Migrations returns the known migrations, ordered by version.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Status returns every known migration along with its applied state.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]MigrationStatus, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Migration: migration}
+			if record, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.Drifted = record.Checksum != migration.Checksum
+				status.AppliedAt = record.AppliedAt
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.migrateTo(ctx, m.latestVersion())
+}
+
+// Down reverts the last n applied migrations and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid number of migrations to revert: %d", n)
+	}
+
+	count := 0
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.verifiedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := sortedVersions(applied)
+		if n > len(versions) {
+			n = len(versions)
+		}
+
+		// Versions are sorted in ascending order, revert from the newest one
+		for i := len(versions) - 1; i >= len(versions)-n; i-- {
+			if err := m.revert(ctx, conn, versions[i]); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Goto migrates the database up or down to the given version.
+// Version 0 reverts every applied migration.
+func (m *Migrator) Goto(ctx context.Context, version int64) (int, error) {
+	if version != 0 {
+		if _, ok := m.find(version); !ok {
+			return 0, fmt.Errorf("unknown migration version: %d", version)
+		}
+	}
+
+	return m.migrateTo(ctx, version)
+}
+
+// migrateTo applies pending migrations up to target and reverts applied migrations above it.
+func (m *Migrator) migrateTo(ctx context.Context, target int64) (int, error) {
+	count := 0
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.verifiedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Revert applied migrations above the target, newest first
+		versions := sortedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && versions[i] > target; i-- {
+			if err := m.revert(ctx, conn, versions[i]); err != nil {
+				return err
+			}
+			count++
+		}
+
+		// Apply pending migrations up to the target, oldest first
+		for _, migration := range m.migrations {
+			if migration.Version > target {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory lock,
+// so that concurrent replicas wait for each other instead of racing.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Pool().Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire database connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		// Use a fresh context so that the lock is released even if ctx has been canceled
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			m.logger.Error().Err(err).Msg("Failed to release migration lock")
+		}
+	}()
+
+	return fn(conn)
+}
+
+// apply runs the up script of a migration and records it in a single transaction.
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	m.logger.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("Applying migration")
+
+	query := `
+		INSERT INTO schema_migrations (version, name, checksum, applied_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, migration.Up); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, query, migration.Version, migration.Name, migration.Checksum, time.Now())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return nil
+}
+
+// revert runs the down script of an applied migration and forgets it in a single transaction.
+func (m *Migrator) revert(ctx context.Context, conn *pgxpool.Conn, version int64) error {
+	migration, ok := m.find(version)
+	if !ok {
+		return fmt.Errorf("applied migration %d is missing from the migration files", version)
+	}
+
+	if strings.TrimSpace(migration.Down) == "" {
+		return fmt.Errorf("migration %d_%s is irreversible", migration.Version, migration.Name)
+	}
+
+	m.logger.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("Reverting migration")
+
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, migration.Down); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return nil
+}
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// appliedMigrations creates the schema_migrations table if needed and returns its content by version.
+func (m *Migrator) appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]appliedMigration, error) {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	records, err := pgx.CollectRows(rows, pgx.RowToStructByPos[appliedMigration])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+// verifiedMigrations returns the applied migrations after checking that none of their files changed.
+func (m *Migrator) verifiedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]appliedMigration, error) {
+	applied, err := m.appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyMigrations(m.migrations, applied); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// find returns the migration with the given version.
+func (m *Migrator) find(version int64) (Migration, bool) {
+	return findMigration(m.migrations, version)
+}
+
+// verifyMigrations checks that none of the applied migrations of list has been modified after being applied.
+func verifyMigrations(list []Migration, applied map[int64]appliedMigration) error {
+	for _, version := range sortedVersions(applied) {
+		migration, ok := findMigration(list, version)
+		if ok && migration.Checksum != applied[version].Checksum {
+			return fmt.Errorf("%w: migration %d_%s has been modified after being applied", ErrMigrationDrift, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// findMigration returns the migration of list with the given version.
+func findMigration(list []Migration, version int64) (Migration, bool) {
+	for _, migration := range list {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// latestVersion returns the highest known migration version.
+func (m *Migrator) latestVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// loadMigrations reads and parses every SQL migration of fsys, ordered by version.
+// A migration is either a single `<version>_<name>.sql` file with an optional down section,
+// or a pair of `<version>_<name>.up.sql` and `<version>_<name>.down.sql` files.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration, len(files))
+	sources := make(map[int64]map[string]string, len(files))
+
+	for _, file := range files {
+		version, name, kind, err := parseMigrationFilename(file)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+			sources[version] = map[string]string{}
+		}
+
+		if migration.Name != name {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, migration.Name, name)
+		}
+
+		// A version may have either one single file, or one up file and one down file
+		for other := range sources[version] {
+			if other == kind || other == migrationKindSingle || kind == migrationKindSingle {
+				return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, sources[version][other], file)
+			}
+		}
+		sources[version][kind] = file
+
+		switch kind {
+		case migrationKindUp:
+			migration.Up = string(content)
+		case migrationKindDown:
+			migration.Down = string(content)
+		default:
+			migration.Up, migration.Down = splitMigration(string(content))
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for version, migration := range byVersion {
+		if _, ok := sources[version][migrationKindDown]; ok {
+			if _, ok := sources[version][migrationKindUp]; !ok {
+				return nil, fmt.Errorf("migration %s has no up file", sources[version][migrationKindDown])
+			}
+		}
+
+		migration.Checksum = checksum(migration.Up)
+		list = append(list, *migration)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+// parseMigrationFilename extracts the version, name and kind from a migration filename.
+func parseMigrationFilename(file string) (int64, string, string, error) {
+	base := strings.TrimSuffix(path.Base(file), ".sql")
+
+	kind := migrationKindSingle
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		base, kind = strings.TrimSuffix(base, ".up"), migrationKindUp
+	case strings.HasSuffix(base, ".down"):
+		base, kind = strings.TrimSuffix(base, ".down"), migrationKindDown
+	}
+
+	prefix, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("invalid migration filename %q: expected <version>_<name>.sql", file)
+	}
+
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("invalid migration filename %q: version must be a positive integer", file)
+	}
+
+	return version, name, kind, nil
+}
+
+// CreateMigration writes an empty pair of up and down migration files into dir.
+// The version is the UTC timestamp of now, so that migrations written concurrently do not collide.
+func CreateMigration(dir string, name string, now time.Time) (string, string, error) {
+	name = strings.Trim(migrationNameSanitizer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", errors.New("invalid migration name")
+	}
+
+	base := filepath.Join(dir, now.UTC().Format("20060102150405")+"_"+name)
+	upPath := base + ".up.sql"
+	downPath := base + ".down.sql"
+
+	files := map[string]string{
+		upPath:   "-- " + name + "\n",
+		downPath: "-- Revert " + name + "\n",
+	}
+
+	for _, file := range []string{upPath, downPath} {
+		//bearer:disable go_gosec_file_permissions_file_perm
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create migration file: %w", err)
+		}
+
+		_, err = f.WriteString(files[file])
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to write migration file %s: %w", file, err)
+		}
+	}
+
+	return upPath, downPath, nil
+}
+
+// splitMigration splits a single-file migration into its up and down sections.
+func splitMigration(content string) (string, string) {
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.EqualFold(strings.TrimSpace(line), migrationDownMarker) {
+			return strings.Join(lines[:i], ""), strings.Join(lines[i+1:], "")
+		}
+	}
+	return content, ""
+}
+
+// checksum returns the hex encoded SHA-256 of a migration script.
+func checksum(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedVersions returns the applied versions in ascending order.
+func sortedVersions(applied map[int64]appliedMigration) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
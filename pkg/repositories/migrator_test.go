@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/samber/do-template-api/migrations"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"002_add_index.sql":  {Data: []byte("CREATE INDEX foo ON users(name);\n")},
+		"001_create_foo.sql": {Data: []byte("CREATE TABLE foo ();\n-- +migrate Down\nDROP TABLE foo;\n")},
+	}
+
+	list, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(list))
+	}
+
+	if list[0].Version != 1 || list[0].Name != "create_foo" {
+		t.Errorf("unexpected first migration: %d_%s", list[0].Version, list[0].Name)
+	}
+	if list[0].Up != "CREATE TABLE foo ();\n" || list[0].Down != "DROP TABLE foo;\n" {
+		t.Errorf("unexpected sections: up=%q down=%q", list[0].Up, list[0].Down)
+	}
+	if list[0].Checksum != checksum(list[0].Up) {
+		t.Errorf("unexpected checksum: %s", list[0].Checksum)
+	}
+
+	if list[1].Version != 2 || list[1].Down != "" {
+		t.Errorf("unexpected second migration: %d_%s down=%q", list[1].Version, list[1].Name, list[1].Down)
+	}
+}
+
+func TestLoadMigrationsPairs(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"001_create_foo.sql":              {Data: []byte("CREATE TABLE foo ();\n")},
+		"20261016120000_add_bar.up.sql":   {Data: []byte("ALTER TABLE foo ADD bar TEXT;\n")},
+		"20261016120000_add_bar.down.sql": {Data: []byte("ALTER TABLE foo DROP bar;\n")},
+	}
+
+	list, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(list))
+	}
+
+	if list[1].Version != 20261016120000 || list[1].Name != "add_bar" {
+		t.Errorf("unexpected second migration: %d_%s", list[1].Version, list[1].Name)
+	}
+	if list[1].Up != "ALTER TABLE foo ADD bar TEXT;\n" || list[1].Down != "ALTER TABLE foo DROP bar;\n" {
+		t.Errorf("unexpected sections: up=%q down=%q", list[1].Up, list[1].Down)
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	upPath, downPath, err := CreateMigration(dir, "Add Users Index", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if filepath.Base(upPath) != "20261016120000_add_users_index.up.sql" {
+		t.Errorf("unexpected up file: %s", upPath)
+	}
+	if filepath.Base(downPath) != "20261016120000_add_users_index.down.sql" {
+		t.Errorf("unexpected down file: %s", downPath)
+	}
+
+	list, err := loadMigrations(os.DirFS(dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list) != 1 || list[0].Version != 20261016120000 {
+		t.Errorf("unexpected migrations: %+v", list)
+	}
+
+	if _, _, err := CreateMigration(dir, "add users index", now); err == nil {
+		t.Error("expected an error when the migration already exists")
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]fstest.MapFS{
+		"missing name":      {"001.sql": {}},
+		"invalid version":   {"abc_foo.sql": {}},
+		"duplicate version": {"001_foo.sql": {}, "1_bar.sql": {}},
+		"single and pair":   {"001_foo.sql": {}, "001_foo.up.sql": {}},
+		"down without up":   {"001_foo.down.sql": {}},
+	}
+
+	for name, fsys := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := loadMigrations(fsys); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	t.Parallel()
+
+	list, err := loadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(list) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+}
+
+func TestApplySQLiteMigrations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db, err := openSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("openSQLite() error = %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	fsys := fstest.MapFS{
+		"001_create_foo.sql": {Data: []byte("CREATE TABLE foo (id INTEGER);\n")},
+		"003_create_baz.sql": {Data: []byte("CREATE TABLE baz (id INTEGER);\n")},
+	}
+	migrate := func() (int, error) {
+		list, err := loadMigrations(fsys)
+		if err != nil {
+			t.Fatalf("loadMigrations() error = %v", err)
+		}
+		return applySQLiteMigrations(ctx, db, list)
+	}
+
+	if count, err := migrate(); err != nil || count != 2 {
+		t.Fatalf("applySQLiteMigrations() = %d, %v, want 2 migrations applied", count, err)
+	}
+
+	// A migration older than the applied ones, e.g. from a branch merged later, is applied too
+	fsys["002_create_bar.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE bar (id INTEGER);\n")}
+	if count, err := migrate(); err != nil || count != 1 {
+		t.Fatalf("applySQLiteMigrations() = %d, %v, want the older migration applied", count, err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO bar (id) VALUES (1)"); err != nil {
+		t.Errorf("table of the older migration is missing: %v", err)
+	}
+
+	if count, err := migrate(); err != nil || count != 0 {
+		t.Fatalf("applySQLiteMigrations() = %d, %v, want nothing left to apply", count, err)
+	}
+
+	fsys["001_create_foo.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE foo (id INTEGER, name TEXT);\n")}
+	if _, err := migrate(); !errors.Is(err, ErrMigrationDrift) {
+		t.Errorf("applySQLiteMigrations() error = %v, want ErrMigrationDrift once an applied migration changed", err)
+	}
+}
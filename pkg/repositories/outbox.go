@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do/v2"
+)
+
+// Types of the user domain events.
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+)
+
+// Event is a domain event, stored in the outbox until it is published
+// Events are delivered at least once: consumers must be idempotent, and may order the events of a user by its version.
+type Event struct {
+	ID          uuid.UUID       `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID uuid.UUID       `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+	// Attempts is the number of failed publications of the event so far.
+	Attempts int `json:"attempts"`
+}
+
+// EventPublisher publishes the events relayed from the outbox, e.g. to a message broker
+// Override the provider of this interface in the injector to plug in another transport.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// logEventPublisher is the default EventPublisher, it logs the events.
+type logEventPublisher struct {
+	logger *zerolog.Logger
+}
+
+// NewEventPublisher creates the default EventPublisher, logging every event
+// This function demonstrates how to provide a pluggable implementation through an interface.
+func NewEventPublisher(injector do.Injector) (EventPublisher, error) {
+	return &logEventPublisher{logger: do.MustInvoke[*zerolog.Logger](injector)}, nil
+}
+
+// Publish implements EventPublisher.
+func (p *logEventPublisher) Publish(ctx context.Context, event Event) error {
+	p.logger.Info().
+		Str("event_id", event.ID.String()).
+		Str("type", event.Type).
+		Str("aggregate_id", event.AggregateID.String()).
+		RawJSON("payload", event.Payload).
+		Msg("Event published")
+	return nil
+}
+
+// eventPublishers publishes the events to every publisher in turn, and fails as soon as one of them fails
+// Publishers must be idempotent, since the events are published to all of them again after a failure.
+type eventPublishers []EventPublisher
+
+// Publish implements EventPublisher.
+func (p eventPublishers) Publish(ctx context.Context, event Event) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordUserEvents writes one event of the given type per user into the outbox, with the user as payload.
+func recordUserEvents(ctx context.Context, q DBTX, eventType string, users ...*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	query := `
+		-- name: RecordUserEvents
+		INSERT INTO outbox (id, type, aggregate_id, payload, created_at)
+		SELECT e.id, $1, e.aggregate_id, e.payload::JSONB, $2
+		FROM unnest($3::UUID[], $4::UUID[], $5::TEXT[]) AS e(id, aggregate_id, payload)
+	`
+
+	ids := make([]string, len(users))
+	aggregateIDs := make([]string, len(users))
+	payloads := make([]string, len(users))
+	for i, user := range users {
+		// UUIDv7 identifiers keep the events of a transaction in order
+		id, err := uuid.NewV7()
+		if err != nil {
+			return fmt.Errorf("failed to generate event ID: %w", err)
+		}
+
+		payload, err := json.Marshal(user)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+		}
+
+		ids[i], aggregateIDs[i], payloads[i] = id.String(), user.ID.String(), string(payload)
+	}
+
+	if _, err := q.Exec(ctx, query, eventType, time.Now(), ids, aggregateIDs, payloads); err != nil {
+		return fmt.Errorf("failed to record %s events: %w", eventType, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// outboxPublishTimeout bounds the duration of the publication of an event.
+	outboxPublishTimeout = 10 * time.Second
+
+	// outboxPurgeInterval is the delay between two purges of the delivered events.
+	outboxPurgeInterval = 10 * time.Minute
+
+	// outboxRetryBackoff is the delay before the first retry of a failed event, doubled on every attempt.
+	outboxRetryBackoff = time.Second
+
+	// Defaults used when the relay settings are not configured.
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 100
+	defaultOutboxMaxBackoff   = 5 * time.Minute
+	defaultOutboxRetention    = 7 * 24 * time.Hour
+)
+
+// OutboxRelay publishes the events of the outbox table
+// Pending events are claimed with a lease, so that several instances of the API can relay them concurrently.
+// Events go to the configured EventPublisher and to the webhooks, an event is delivered once both accepted it.
+// Failed events are retried with an exponential backoff, and delivered events are purged after `database.outbox_retention` seconds.
+type OutboxRelay struct {
+	db           *Database       `do:""`
+	publisher    EventPublisher  `do:""`
+	logger       *zerolog.Logger `do:""`
+	pollInterval time.Duration
+	batchSize    int
+	backoff      Backoff
+	retention    time.Duration
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewOutboxRelay creates the outbox relay and starts polling in the background
+// This function demonstrates how to initialize a service running a background task.
+func NewOutboxRelay(injector do.Injector) (*OutboxRelay, error) {
+	appConfig := do.MustInvoke[*config.Config](injector)
+	cfg := appConfig.Database
+
+	driver, err := databaseDriver(appConfig)
+	if err != nil {
+		return nil, err
+	}
+	if driver != DriverPostgres {
+		return nil, fmt.Errorf("%w: the %s database driver has no outbox", ErrUnsupportedDriver, driver)
+	}
+
+	relay := do.MustInvokeStruct[*OutboxRelay](injector)
+	relay.publisher = eventPublishers{relay.publisher, do.MustInvoke[WebhookRepository](injector)}
+	relay.pollInterval = DurationOr(time.Duration(cfg.OutboxPollInterval)*time.Millisecond, defaultOutboxPollInterval)
+	relay.batchSize = cfg.OutboxBatchSize
+	if relay.batchSize <= 0 {
+		relay.batchSize = defaultOutboxBatchSize
+	}
+	relay.backoff = Backoff{
+		Initial: outboxRetryBackoff,
+		Max:     DurationOr(time.Duration(cfg.OutboxMaxBackoff)*time.Second, defaultOutboxMaxBackoff),
+	}
+	relay.retention = DurationOr(time.Duration(cfg.OutboxRetention)*time.Second, defaultOutboxRetention)
+
+	relay.start()
+
+	return relay, nil
+}
+
+// start relays the pending events every poll interval, and purges the delivered ones.
+func (r *OutboxRelay) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		poll := time.NewTimer(0)
+		defer poll.Stop()
+		purge := time.NewTicker(outboxPurgeInterval)
+		defer purge.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-poll.C:
+				delay := r.pollInterval
+
+				// Wait for the database to come up when connecting lazily
+				if r.db.Ready() {
+					count, err := r.relayBatch(ctx)
+					if err != nil && ctx.Err() == nil {
+						r.logger.Warn().Err(err).Msg("Failed to relay outbox events")
+					}
+					// A full batch means that more events are pending
+					if count == r.batchSize {
+						delay = 0
+					}
+				}
+
+				poll.Reset(delay)
+			case <-purge.C:
+				if err := r.purge(ctx); err != nil && ctx.Err() == nil {
+					r.logger.Warn().Err(err).Msg("Failed to purge delivered outbox events")
+				}
+			}
+		}
+	}()
+}
+
+// relayBatch publishes a batch of pending events, and returns how many were claimed
+// Events are claimed with a lease long enough to publish the whole batch, so that no other relay publishes them concurrently
+// while no transaction stays open. The events of a crashed relay are published again once their lease expires.
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	query := `
+		-- name: ClaimOutboxEvents
+		WITH claimed AS (
+			UPDATE outbox
+			SET next_attempt_at = now() + $2::INTERVAL
+			WHERE id IN (
+				SELECT id FROM outbox
+				WHERE delivered_at IS NULL AND next_attempt_at <= now()
+				ORDER BY next_attempt_at, id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, type, aggregate_id, payload, created_at, attempts
+		)
+		SELECT * FROM claimed ORDER BY created_at, id
+	`
+
+	lease := time.Duration(r.batchSize) * outboxPublishTimeout
+	rows, err := r.db.Pool().Query(ctx, query, r.batchSize, lease)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		var event Event
+		err := row.Scan(&event.ID, &event.Type, &event.AggregateID, &event.Payload, &event.CreatedAt, &event.Attempts)
+		return event, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan outbox events: %w", err)
+	}
+
+	var delivered, released []string
+	var failed []outboxFailure
+	for _, event := range events {
+		// Events left unpublished on shutdown are released below, instead of waiting for their lease to expire
+		if ctx.Err() != nil {
+			released = append(released, event.ID.String())
+			continue
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, outboxPublishTimeout)
+		err := r.publisher.Publish(publishCtx, event)
+		cancel()
+
+		switch {
+		case err == nil:
+			delivered = append(delivered, event.ID.String())
+		case ctx.Err() != nil:
+			released = append(released, event.ID.String())
+		default:
+			failed = append(failed, outboxFailure{event: event, err: err})
+		}
+	}
+
+	// The outcomes are stored even on shutdown, so that the published events are not published again
+	err = pgx.BeginFunc(context.WithoutCancel(ctx), r.db.Pool(), func(tx pgx.Tx) error {
+		return r.markBatch(context.WithoutCancel(ctx), tx, delivered, released, failed)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), ctx.Err()
+}
+
+// outboxFailure is an event whose publication failed.
+type outboxFailure struct {
+	event Event
+	err   error
+}
+
+// markBatch stores the outcome of the publication of a batch: delivered events, released ones, and failures to retry with a backoff.
+func (r *OutboxRelay) markBatch(ctx context.Context, tx pgx.Tx, delivered, released []string, failed []outboxFailure) error {
+	for _, failure := range failed {
+		event, err := failure.event, failure.err
+		delay := r.backoff.Delay(event.Attempts + 1)
+		r.logger.Warn().Err(err).Str("event_id", event.ID.String()).Str("type", event.Type).
+			Int("attempt", event.Attempts+1).Dur("retry_in", delay).Msg("Failed to publish outbox event")
+
+		query := `
+			-- name: RetryOutboxEvent
+			UPDATE outbox
+			SET attempts = attempts + 1, next_attempt_at = now() + $2::INTERVAL, last_error = $3
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(ctx, query, event.ID, delay, err.Error()); err != nil {
+			return fmt.Errorf("failed to reschedule outbox event: %w", err)
+		}
+	}
+
+	if len(delivered) > 0 {
+		query := `
+			-- name: MarkOutboxEventsDelivered
+			UPDATE outbox SET delivered_at = now() WHERE id = ANY($1::UUID[])
+		`
+		if _, err := tx.Exec(ctx, query, delivered); err != nil {
+			return fmt.Errorf("failed to mark outbox events delivered: %w", err)
+		}
+	}
+
+	if len(released) > 0 {
+		query := `
+			-- name: ReleaseOutboxEvents
+			UPDATE outbox SET next_attempt_at = now() WHERE id = ANY($1::UUID[])
+		`
+		if _, err := tx.Exec(ctx, query, released); err != nil {
+			return fmt.Errorf("failed to release outbox events: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// purge deletes the events delivered for longer than the retention.
+func (r *OutboxRelay) purge(ctx context.Context) error {
+	query := `
+		-- name: PurgeOutboxEvents
+		DELETE FROM outbox WHERE delivered_at < now() - $1::INTERVAL
+	`
+
+	if _, err := r.db.Pool().Exec(ctx, query, r.retention); err != nil {
+		return fmt.Errorf("failed to purge outbox events: %w", err)
+	}
+	return nil
+}
+
+// Shutdown stops relaying, the events of the batch in progress which were not published yet are released.
+func (r *OutboxRelay) Shutdown() error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+	return nil
+}
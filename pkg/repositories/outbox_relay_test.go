@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// recordingEventPublisher records the published events, failing the first publication when fail is set.
+type recordingEventPublisher struct {
+	events []Event
+	fail   bool
+}
+
+func (p *recordingEventPublisher) Publish(ctx context.Context, event Event) error {
+	if p.fail {
+		p.fail = false
+		return errors.New("broker unavailable")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// TestOutboxRelay runs against the database of TEST_DATABASE_URL, sequentially since it shares the users table.
+func TestOutboxRelay(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	injector := do.New(Package)
+	do.ProvideValue(injector, &config.Config{Database: config.DatabaseConfig{Driver: DriverPostgres, URL: url}})
+	do.ProvideValue(injector, &logger)
+	t.Cleanup(func() { injector.Shutdown() })
+
+	db := do.MustInvoke[*Database](injector)
+	if _, err := do.MustInvoke[*Migrator](injector).Up(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if _, err := db.Pool().Exec(ctx, `TRUNCATE users, outbox`); err != nil {
+		t.Fatalf("failed to empty tables: %v", err)
+	}
+
+	repo := do.MustInvoke[UserRepository](injector)
+	user, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := repo.DeleteUser(ctx, user.ID, 0); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	// The relay is not started, batches are relayed by hand
+	publisher := &recordingEventPublisher{fail: true}
+	relay := &OutboxRelay{
+		db:        db,
+		publisher: publisher,
+		logger:    &logger,
+		batchSize: 10,
+		backoff:   Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+	}
+
+	if count, err := relay.relayBatch(ctx); err != nil || count != 2 {
+		t.Fatalf("relayBatch() = %d, %v, want 2 events", count, err)
+	}
+	if len(publisher.events) != 1 || publisher.events[0].Type != EventUserDeleted {
+		t.Fatalf("published %v, want the second event once the first failed", publisher.events)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if count, err := relay.relayBatch(ctx); err != nil || count != 1 {
+		t.Fatalf("relayBatch() = %d, %v, want the failed event to be retried", count, err)
+	}
+	retried := publisher.events[1]
+	if retried.Type != EventUserCreated || retried.AggregateID != user.ID || retried.Attempts != 1 {
+		t.Errorf("retried event = %+v, want the user.created event at its second attempt", retried)
+	}
+
+	if count, err := relay.relayBatch(ctx); err != nil || count != 0 {
+		t.Errorf("relayBatch() = %d, %v, want delivered events not to be published again", count, err)
+	}
+}
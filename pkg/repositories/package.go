@@ -4,5 +4,13 @@ import "github.com/samber/do/v2"
 
 var Package = do.Package(
 	do.Lazy(NewDatabase),
+	do.Lazy(NewSQLiteDatabase),
+	do.Lazy(NewTxManager),
 	do.Lazy(NewUserRepository),
+	do.Lazy(NewMigrator),
+	do.Lazy(NewIdempotencyStore),
+	do.Lazy(NewEventPublisher),
+	do.Lazy(NewOutboxRelay),
+	do.Lazy(NewWebhookRepository),
+	do.Lazy(NewUserEventStream),
 )
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// TotalMode selects how the total number of items of a listing is counted.
+type TotalMode string
+
+const (
+	// TotalNone skips counting, which is the cheapest option.
+	TotalNone TotalMode = ""
+	// TotalExact counts every matching row.
+	TotalExact TotalMode = "exact"
+	// TotalEstimated reads the row estimate of the query planner, which is instant but approximate.
+	TotalEstimated TotalMode = "estimated"
+)
+
+// ParseTotalMode validates a total mode.
+func ParseTotalMode(mode string) (TotalMode, error) {
+	switch TotalMode(mode) {
+	case TotalNone, TotalExact, TotalEstimated:
+		return TotalMode(mode), nil
+	default:
+		return TotalNone, newError(ErrInvalid, "invalid total mode, expected exact or estimated", nil)
+	}
+}
+
+// Cursor points at an item of a listing, by the values of its sort keys and its ID
+// This demonstrates keyset pagination, which stays fast and stable while rows are inserted.
+type Cursor struct {
+	// Sort is the sort order the cursor was built for, a cursor is only valid for that order.
+	Sort   string
+	Values []string
+	ID     uuid.UUID
+	// Backward selects the items before the cursor instead of the items after it.
+	Backward bool
+}
+
+// cursorPayload is the serialized form of a Cursor.
+type cursorPayload struct {
+	Sort     string    `json:"s"`
+	Values   []string  `json:"v"`
+	ID       uuid.UUID `json:"id"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// Encode returns the opaque, URL-safe representation of the cursor.
+func (c Cursor) Encode() string {
+	payload, _ := json.Marshal(cursorPayload(c))
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeCursor parses a cursor returned by Cursor.Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, newError(ErrInvalid, "invalid pagination cursor", err)
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, newError(ErrInvalid, "invalid pagination cursor", err)
+	}
+	if payload.Sort == "" || len(payload.Values) == 0 || payload.ID == uuid.Nil {
+		return nil, newError(ErrInvalid, "invalid pagination cursor", nil)
+	}
+
+	cursor := Cursor(payload)
+	return &cursor, nil
+}
+
+// sqlArgs accumulates the arguments of a query built at runtime
+// Values are always passed as arguments, never concatenated to the query.
+type sqlArgs []any
+
+// add appends value to the arguments and returns its placeholder.
+func (a *sqlArgs) add(value any) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// paginate trims the rows fetched for a page, and returns the items bounding its neighbour pages
+// rows holds up to limit+1 items in the fetch order, the extra item revealing whether more items follow.
+// Backward pages are fetched in reverse order, and are put back in listing order.
+func paginate[T any](rows []T, limit int, backward, hasCursor bool) (items []T, next, prev *T) {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		return rows, nil, nil
+	}
+
+	// Items follow a backward page, since it was reached from them, and precede a forward page reached through a cursor
+	if more || backward {
+		next = &rows[len(rows)-1]
+	}
+	if (more && backward) || (hasCursor && !backward) {
+		prev = &rows[0]
+	}
+
+	return rows, next, prev
+}
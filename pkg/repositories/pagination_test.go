@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorEncoding(t *testing.T) {
+	t.Parallel()
+
+	cursor := Cursor{
+		Sort:     "-created_at,name",
+		Values:   []string{"2024-05-01T12:30:00.123456Z", "John"},
+		ID:       uuid.MustParse("0190f0a4-5b1e-7c4e-9d6a-2f1c3e4b5a69"),
+		Backward: true,
+	}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if decoded.Sort != cursor.Sort || !slices.Equal(decoded.Values, cursor.Values) || decoded.ID != cursor.ID || !decoded.Backward {
+		t.Errorf("DecodeCursor() = %+v, want %+v", decoded, cursor)
+	}
+
+	for _, raw := range []string{"", "not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := DecodeCursor(raw); !errors.Is(err, ErrInvalid) {
+			t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalid", raw, err)
+		}
+	}
+}
+
+func TestParseTotalMode(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"", "exact", "estimated"} {
+		if got, err := ParseTotalMode(mode); err != nil || string(got) != mode {
+			t.Errorf("ParseTotalMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+
+	if _, err := ParseTotalMode("approximate"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ParseTotalMode() error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	t.Parallel()
+
+	// Items in listing order
+	items := []int{0, 1, 2, 3, 4}
+
+	reversed := func(list []int) []int {
+		out := slices.Clone(list)
+		slices.Reverse(out)
+		return out
+	}
+
+	testCases := map[string]struct {
+		rows                []int
+		backward, hasCursor bool
+		want                []int
+		next, prev          int
+	}{
+		"first page":          {rows: items[:3], want: items[:2], next: 1, prev: -1},
+		"single page":         {rows: items[:2], want: items[:2], next: -1, prev: -1},
+		"middle page":         {rows: items[2:5], hasCursor: true, want: items[2:4], next: 3, prev: 2},
+		"last page":           {rows: items[4:], hasCursor: true, want: items[4:], next: -1, prev: 4},
+		"previous page":       {rows: reversed(items[0:3]), backward: true, hasCursor: true, want: items[1:3], next: 2, prev: 1},
+		"previous first page": {rows: reversed(items[0:2]), backward: true, hasCursor: true, want: items[0:2], next: 1, prev: -1},
+		"empty":               {hasCursor: true, next: -1, prev: -1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, next, prev := paginate(slices.Clone(tc.rows), 2, tc.backward, tc.hasCursor)
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("paginate() = %v, want %v", got, tc.want)
+			}
+			assertBoundary(t, "next", next, tc.next)
+			assertBoundary(t, "prev", prev, tc.prev)
+		})
+	}
+}
+
+func assertBoundary(t *testing.T, name string, got *int, want int) {
+	t.Helper()
+
+	switch {
+	case want < 0 && got != nil:
+		t.Errorf("%s = %d, want none", name, *got)
+	case want >= 0 && (got == nil || *got != want):
+		t.Errorf("%s = %v, want %d", name, got, want)
+	}
+}
@@ -3,9 +3,16 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
 	"github.com/samber/do-template-api/pkg/config"
 	"github.com/samber/do/v2"
 )
@@ -13,7 +20,11 @@ import (
 // Database represents a PostgreSQL connection pool
 // This service demonstrates how to create and manage database connections using dependency injection.
 type Database struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	replicas *replicaSet
+	ready    atomic.Bool
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
 }
 
 // NewDatabase creates a new PostgreSQL database connection pool
@@ -21,48 +32,182 @@ type Database struct {
 func NewDatabase(injector do.Injector) (*Database, error) {
 	// Get configuration from the injector
 	appConfig := do.MustInvoke[*config.Config](injector)
+	logger := do.MustInvoke[*zerolog.Logger](injector)
 	cfg := appConfig.Database
 
-	// Build connection string
-	connString := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s pool_max_conns=%d pool_min_conns=%d pool_max_conn_lifetime=%s",
-		cfg.Host,
-		cfg.Port,
-		cfg.User,
-		cfg.Password,
-		cfg.Database,
-		cfg.SSLMode,
-		cfg.MaxOpenConns,
-		cfg.MaxIdleConns,
-		time.Duration(cfg.ConnMaxLifetime)*time.Second,
-	)
-
 	// Create connection pool config
+	poolConfig, err := newPoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log every query, and warn about slow ones
+	poolConfig.ConnConfig.Tracer = newQueryTracer(logger, time.Duration(cfg.SlowQueryThreshold)*time.Millisecond)
+
+	// Create connection pool
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	policy := connectPolicy{
+		Backoff: Backoff{
+			Initial: time.Duration(cfg.ConnectBackoff) * time.Millisecond,
+			Max:     time.Duration(cfg.ConnectMaxBackoff) * time.Millisecond,
+		},
+		retries: cfg.ConnectRetries,
+		timeout: time.Duration(cfg.ConnectTimeout) * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db := &Database{pool: pool, cancel: cancel}
+
+	if cfg.LazyConnect {
+		// Start right away in a not-ready state, and keep trying to connect in the background
+		policy.retries, policy.timeout = -1, 0
+
+		db.wg.Add(1)
+		go func() {
+			defer db.wg.Done()
+
+			if err := connect(ctx, pool, policy, logger); err != nil {
+				// Being canceled on shutdown is expected
+				if ctx.Err() == nil {
+					logger.Error().Err(err).Msg("Gave up connecting to database")
+				}
+				return
+			}
+			db.ready.Store(true)
+		}()
+	} else {
+		// Test the connection, waiting for the database to come up
+		if err := connect(ctx, pool, policy, logger); err != nil {
+			cancel()
+			pool.Close()
+			return nil, err
+		}
+		db.ready.Store(true)
+	}
+
+	// Create one pool per read replica, sharing the primary settings
+	replicas, err := newReplicaSet(poolConfig, cfg.Replicas, int(poolConfig.ConnConfig.Port), logger)
+	if err != nil {
+		_ = db.Shutdown()
+		return nil, err
+	}
+	replicas.start(time.Duration(cfg.ReplicaCheckInterval) * time.Second)
+	db.replicas = replicas
+
+	return db, nil
+}
+
+// newPoolConfig builds the connection pool configuration from either the `database.url` DSN,
+// or the individual connection settings. The password is never part of the connection string.
+func newPoolConfig(cfg config.DatabaseConfig) (*pgxpool.Config, error) {
+	connString, err := buildConnString(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	poolConfig, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database config: %w", err)
 	}
 
+	if cfg.URL == "" && cfg.Password != "" {
+		poolConfig.ConnConfig.Password = cfg.Password
+	}
+
 	// Additional pool configuration
-	poolConfig.MaxConns = int32(cfg.MaxOpenConns)
-	poolConfig.MinConns = int32(cfg.MaxIdleConns)
-	poolConfig.MaxConnLifetime = time.Duration(cfg.ConnMaxLifetime) * time.Second
+	if cfg.MaxOpenConns > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		poolConfig.MinConns = int32(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		poolConfig.MaxConnLifetime = time.Duration(cfg.ConnMaxLifetime) * time.Second
+	}
 	poolConfig.HealthCheckPeriod = 1 * time.Minute
 	poolConfig.MaxConnIdleTime = 5 * time.Minute
 
-	// Create connection pool
-	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	// Session settings sent when connecting
+	runtimeParams := map[string]string{
+		"application_name":                    cfg.ApplicationName,
+		"statement_timeout":                   millisecondsParam(cfg.StatementTimeout),
+		"lock_timeout":                        millisecondsParam(cfg.LockTimeout),
+		"idle_in_transaction_session_timeout": millisecondsParam(cfg.IdleInTxTimeout),
+	}
+	for key, value := range runtimeParams {
+		if value != "" {
+			poolConfig.ConnConfig.RuntimeParams[key] = value
+		}
 	}
 
-	// Test the connection
-	if err := pool.Ping(context.Background()); err != nil {
-		pool.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	// PgBouncer in transaction pooling mode does not support prepared statements
+	if cfg.SimpleProtocol {
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
 	}
 
-	return &Database{pool: pool}, nil
+	return poolConfig, nil
+}
+
+// buildConnString returns the `database.url` DSN completed with the TLS settings,
+// or a key/value connection string built from the individual connection settings.
+func buildConnString(cfg config.DatabaseConfig) (string, error) {
+	tlsParams := [][2]string{
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	}
+
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse database url: %w", err)
+		}
+
+		// Parameters of the DSN take precedence over the individual settings
+		query := u.Query()
+		for _, param := range tlsParams {
+			if param[1] != "" && !query.Has(param[0]) {
+				query.Set(param[0], param[1])
+			}
+		}
+		u.RawQuery = query.Encode()
+
+		return u.String(), nil
+	}
+
+	params := [][2]string{
+		{"host", cfg.Host},
+		{"port", strconv.Itoa(cfg.Port)},
+		{"user", cfg.User},
+		{"dbname", cfg.Database},
+		{"sslmode", cfg.SSLMode},
+	}
+
+	pairs := make([]string, 0, len(params)+len(tlsParams))
+	for _, param := range append(params, tlsParams...) {
+		if param[1] != "" && param[1] != "0" {
+			pairs = append(pairs, param[0]+"="+quoteConnParam(param[1]))
+		}
+	}
+
+	return strings.Join(pairs, " "), nil
+}
+
+// quoteConnParam quotes a key/value connection string value.
+func quoteConnParam(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// millisecondsParam formats a duration in milliseconds as a runtime parameter, omitting zero values.
+func millisecondsParam(ms int) string {
+	if ms <= 0 {
+		return ""
+	}
+	return strconv.Itoa(ms)
 }
 
 // Pool returns the underlying pgxpool.Pool
@@ -71,9 +216,31 @@ func (db *Database) Pool() *pgxpool.Pool {
 	return db.pool
 }
 
-// HealthCheckWithContext the database connection
-// This method demonstrates how to implement health checks for services.
-func (db *Database) HealthCheckWithContext(ctx context.Context) error {
+// Reader returns the pool serving read-only queries
+// Reads are spread across healthy replicas, and fall back to the primary when none is available or when ctx was built with WithPrimary.
+func (db *Database) Reader(ctx context.Context) *pgxpool.Pool {
+	if usePrimary(ctx) || db.replicas == nil {
+		return db.pool
+	}
+
+	if pool := db.replicas.pick(); pool != nil {
+		return pool
+	}
+
+	return db.pool
+}
+
+// Ready reports whether the database has been reached at least once.
+func (db *Database) Ready() bool {
+	return db.ready.Load()
+}
+
+// HealthCheck checks the database connection
+// This method implements do.HealthcheckerWithContext, so that the injector health checks include the database.
+func (db *Database) HealthCheck(ctx context.Context) error {
+	if !db.ready.Load() {
+		return ErrDatabaseNotReady
+	}
 	if err := db.pool.Ping(ctx); err != nil {
 		return fmt.Errorf("database health check failed: %w", err)
 	}
@@ -82,6 +249,14 @@ func (db *Database) HealthCheckWithContext(ctx context.Context) error {
 
 // Shutdown the database connection.
 func (db *Database) Shutdown() error {
+	if db.cancel != nil {
+		db.cancel()
+	}
+	db.wg.Wait()
+
+	if db.replicas != nil {
+		db.replicas.close()
+	}
 	if db.pool != nil {
 		db.pool.Close()
 	}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// replicaPingTimeout bounds the duration of a replica health check.
+	replicaPingTimeout = 5 * time.Second
+
+	// defaultReplicaCheckInterval is used when no health check interval is configured.
+	defaultReplicaCheckInterval = 10 * time.Second
+)
+
+// primaryContextKey is the context key forcing reads on the primary.
+type primaryContextKey struct{}
+
+// WithPrimary returns a context whose reads are routed to the primary instead of a replica
+// Use it right after a write, when replication lag would otherwise return stale data.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// usePrimary reports whether ctx forces reads on the primary.
+func usePrimary(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryContextKey{}).(bool)
+	return forced
+}
+
+// replica is a read-only connection pool with its health state.
+type replica struct {
+	addr    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// replicaSet routes reads to healthy replicas in a round robin fashion
+// Replicas failing their health check are ejected until they answer again.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	logger   *zerolog.Logger
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// newReplicaSet creates one pool per replica address, reusing the primary pool configuration.
+// Addresses are either `host` or `host:port`, defaultPort being used when the port is omitted.
+func newReplicaSet(primary *pgxpool.Config, addrs []string, defaultPort int, logger *zerolog.Logger) (*replicaSet, error) {
+	set := &replicaSet{logger: logger}
+
+	for _, addr := range addrs {
+		host, port := addr, defaultPort
+		if h, p, err := net.SplitHostPort(addr); err == nil {
+			if port, err = strconv.Atoi(p); err != nil || port <= 0 || port > 65535 {
+				set.close()
+				return nil, fmt.Errorf("invalid replica address %q", addr)
+			}
+			host = h
+		}
+
+		poolConfig := primary.Copy()
+		poolConfig.ConnConfig.Host = host
+		poolConfig.ConnConfig.Port = uint16(port)
+		poolConfig.ConnConfig.Fallbacks = nil
+
+		// Certificates must be verified against the replica hostname
+		if poolConfig.ConnConfig.TLSConfig != nil {
+			poolConfig.ConnConfig.TLSConfig = poolConfig.ConnConfig.TLSConfig.Clone()
+			poolConfig.ConnConfig.TLSConfig.ServerName = host
+		}
+
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("failed to create replica connection pool %s: %w", addr, err)
+		}
+
+		set.replicas = append(set.replicas, &replica{addr: addr, pool: pool})
+	}
+
+	return set, nil
+}
+
+// start checks every replica once, then keeps checking them in the background every interval.
+func (s *replicaSet) start(interval time.Duration) {
+	if len(s.replicas) == 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.checkAll(ctx)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+// checkAll pings every replica and updates its health state.
+func (s *replicaSet) checkAll(ctx context.Context) {
+	for _, r := range s.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := r.pool.Ping(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) == healthy {
+			continue
+		}
+
+		if healthy {
+			s.logger.Info().Str("replica", r.addr).Msg("Database replica is healthy, routing reads to it")
+		} else {
+			s.logger.Warn().Err(err).Str("replica", r.addr).Msg("Database replica is unhealthy, ejecting it")
+		}
+	}
+}
+
+// pick returns the next healthy replica pool, or nil when none is available.
+func (s *replicaSet) pick() *pgxpool.Pool {
+	healthy := make([]*pgxpool.Pool, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r.pool)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	return healthy[s.next.Add(1)%uint64(len(healthy))]
+}
+
+// close stops the health checks and closes every replica pool.
+func (s *replicaSet) close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	for _, r := range s.replicas {
+		r.pool.Close()
+	}
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+func TestReplicaSetPick(t *testing.T) {
+	t.Parallel()
+
+	primary, err := pgxpool.ParseConfig("host=primary port=5432 user=postgres")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	logger := zerolog.Nop()
+	set, err := newReplicaSet(primary, []string{"replica-a", "replica-b:5433", "replica-c"}, 5432, &logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer set.close()
+
+	if got := set.replicas[1].pool.Config().ConnConfig.Port; got != 5433 {
+		t.Errorf("expected replica-b on port 5433, got %d", got)
+	}
+
+	// No replica is healthy yet
+	if pool := set.pick(); pool != nil {
+		t.Error("expected no replica to be picked")
+	}
+
+	set.replicas[0].healthy.Store(true)
+	set.replicas[2].healthy.Store(true)
+
+	seen := map[*pgxpool.Pool]int{}
+	for range 10 {
+		seen[set.pick()]++
+	}
+
+	if seen[set.replicas[0].pool] != 5 || seen[set.replicas[2].pool] != 5 {
+		t.Errorf("expected reads to be spread across healthy replicas, got %v", seen)
+	}
+	if seen[set.replicas[1].pool] != 0 {
+		t.Error("expected unhealthy replica to be ejected")
+	}
+}
+
+func TestDatabaseReaderWithPrimary(t *testing.T) {
+	t.Parallel()
+
+	primary, err := pgxpool.New(context.Background(), "host=primary user=postgres")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	logger := zerolog.Nop()
+	set, err := newReplicaSet(primary.Config(), []string{"replica"}, 5432, &logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	set.replicas[0].healthy.Store(true)
+
+	db := &Database{pool: primary, replicas: set}
+	defer func() { _ = db.Shutdown() }()
+
+	if db.Reader(context.Background()) != set.replicas[0].pool {
+		t.Error("expected reads to be routed to the replica")
+	}
+	if db.Reader(WithPrimary(context.Background())) != primary {
+		t.Error("expected reads to be routed to the primary")
+	}
+}
@@ -0,0 +1,262 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/migrations"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// defaultSQLitePath is used when no database file is configured.
+	defaultSQLitePath = "do-template-api.db"
+
+	// sqliteBusyTimeout is how long a connection waits for a lock held by another one, in milliseconds.
+	sqliteBusyTimeout = 5000
+
+	// sqliteTimeLayout formats timestamps with a fixed precision, so that they sort as text.
+	sqliteTimeLayout = "2006-01-02T15:04:05.000000Z"
+)
+
+// sqliteConstraintDetails maps the columns of SQLite unique constraints to client-safe details, like constraintDetails.
+var sqliteConstraintDetails = map[string]string{
+	"users.email": constraintDetails["users_email_key"],
+}
+
+func init() {
+	// pg_trgm is not available, the similarity of the search is computed in Go
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		a, _ := args[0].(string)
+		b, _ := args[1].(string)
+		return trigramSimilarity(a, b), nil
+	})
+}
+
+// SQLiteDatabase is an embedded SQLite database, for single-node deployments
+// Its migrations are applied when it is opened.
+type SQLiteDatabase struct {
+	db *sql.DB
+}
+
+// NewSQLiteDatabase opens the SQLite database at `database.path` and applies its pending migrations
+// This function demonstrates how to initialize an alternative storage service using samber/do.
+func NewSQLiteDatabase(injector do.Injector) (*SQLiteDatabase, error) {
+	cfg := do.MustInvoke[*config.Config](injector).Database
+	logger := do.MustInvoke[*zerolog.Logger](injector)
+
+	path := cfg.Path
+	if path == "" {
+		path = defaultSQLitePath
+	}
+
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := migrateSQLite(context.Background(), db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	logger.Info().Str("path", path).Int("migrations", count).Msg("SQLite database opened")
+
+	return &SQLiteDatabase{db: db}, nil
+}
+
+// openSQLite opens the SQLite database file at path, or an in-memory database for `:memory:`.
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path, sqliteBusyTimeout)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	// SQLite serializes writes anyway, and an in-memory database only lives in its connection
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	return db, nil
+}
+
+// migrateSQLite applies the pending SQLite migrations, each in its own transaction, and returns how many were applied.
+func migrateSQLite(ctx context.Context, db *sql.DB) (int, error) {
+	fsys, err := fs.Sub(migrations.SQLiteFS, "sqlite")
+	if err != nil {
+		return 0, fmt.Errorf("failed to load SQLite migrations: %w", err)
+	}
+
+	list, err := loadMigrations(fsys)
+	if err != nil {
+		return 0, err
+	}
+
+	return applySQLiteMigrations(ctx, db, list)
+}
+
+// applySQLiteMigrations applies every migration of list which is not applied yet, including those older than the applied ones
+// Like the PostgreSQL Migrator, it fails when an applied migration has been modified afterward.
+func applySQLiteMigrations(ctx context.Context, db *sql.DB, list []Migration) (int, error) {
+	applied, err := appliedSQLiteMigrations(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := verifyMigrations(list, applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range list {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := sqliteTx(ctx, db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+				return err
+			}
+
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`,
+				migration.Version, migration.Name, migration.Checksum, formatSQLiteTime(time.Now()))
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// appliedSQLiteMigrations creates the schema_migrations table if needed and returns its content by version.
+func appliedSQLiteMigrations(ctx context.Context, db *sql.DB) (map[int64]appliedMigration, error) {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		)
+	`
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var record appliedMigration
+		var appliedAt string
+		if err := rows.Scan(&record.Version, &record.Name, &record.Checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migrations: %w", err)
+		}
+		if record.AppliedAt, err = parseSQLiteTime(appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migrations: %w", err)
+		}
+		applied[record.Version] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+// sqliteTx runs fn in a transaction, committed when fn returns nil.
+func sqliteTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// HealthCheck checks the database connection
+// This method implements do.HealthcheckerWithContext, so that the injector health checks include the database.
+func (d *SQLiteDatabase) HealthCheck(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+// Shutdown closes the database.
+func (d *SQLiteDatabase) Shutdown() error {
+	return d.db.Close()
+}
+
+// formatSQLiteTime formats a timestamp as stored in SQLite.
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// parseSQLiteTime parses a timestamp stored in SQLite.
+func parseSQLiteTime(s string) (time.Time, error) {
+	return time.Parse(sqliteTimeLayout, s)
+}
+
+// sqliteValues converts the timestamps of dynamic query arguments to their stored representation.
+func sqliteValues(args sqlArgs) []any {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			arg = formatSQLiteTime(t)
+		}
+		values[i] = arg
+	}
+	return values
+}
+
+// translateSQLiteError converts database/sql and SQLite errors about resource into sentinel errors, like translateError.
+func translateSQLiteError(err error, resource string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(ErrNotFound, resource+" not found", err)
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		// Messages name the columns, e.g. "UNIQUE constraint failed: users.email"
+		detail := resource + " already exists"
+		for columns, columnsDetail := range sqliteConstraintDetails {
+			if strings.Contains(sqliteErr.Error(), "constraint failed: "+columns) {
+				detail = columnsDetail
+			}
+		}
+		return newError(ErrConflict, detail, err)
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL, sqlite3.SQLITE_CONSTRAINT_CHECK:
+		return newError(ErrInvalid, "invalid "+resource, err)
+	}
+
+	return err
+}
@@ -0,0 +1,470 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sqliteDBTX is the set of query methods shared by sql.DB and sql.Tx.
+type sqliteDBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqliteRow is a row of a query result, either a sql.Row or the current row of sql.Rows.
+type sqliteRow interface {
+	Scan(dest ...any) error
+}
+
+// sqliteUserRepository implements the UserRepository interface on SQLite
+// Queries mirror the PostgreSQL ones, with timestamps stored as text and the search similarity computed in Go.
+type sqliteUserRepository struct {
+	db *SQLiteDatabase
+}
+
+// CreateUser implements UserRepository.
+func (r *sqliteUserRepository) CreateUser(ctx context.Context, user *User) (*User, error) {
+	created, err := r.create(ctx, r.db.db, user, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	*user = *created
+	return user, nil
+}
+
+// create inserts user with a new ID.
+func (r *sqliteUserRepository) create(ctx context.Context, q sqliteDBTX, user *User, now time.Time) (*User, error) {
+	query := `
+		-- name: CreateUser
+		INSERT INTO users (id, name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user ID: %w", err)
+	}
+
+	created, err := scanSQLiteUser(q.QueryRowContext(ctx, query, id, user.Name, user.Email, formatSQLiteTime(now)))
+	if err != nil {
+		return nil, translateSQLiteError(err, "user")
+	}
+
+	return created, nil
+}
+
+// GetUserByID implements UserRepository.
+func (r *sqliteUserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	user, err := r.getByID(ctx, r.db.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+	return user, nil
+}
+
+// getByID reads a user, soft-deleted ones only when ctx includes them.
+func (r *sqliteUserRepository) getByID(ctx context.Context, q sqliteDBTX, id uuid.UUID) (*User, error) {
+	query := `
+		-- name: GetUserByID
+		SELECT id, name, email, created_at, updated_at, version, deleted_at
+		FROM users
+		WHERE id = $1 AND ($2 OR deleted_at IS NULL)
+	`
+
+	user, err := scanSQLiteUser(q.QueryRowContext(ctx, query, id, includeDeleted(ctx)))
+	if err != nil {
+		return nil, translateSQLiteError(err, "user")
+	}
+	return user, nil
+}
+
+// GetUserByEmail implements UserRepository.
+func (r *sqliteUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	query := `
+		-- name: GetUserByEmail
+		SELECT id, name, email, created_at, updated_at, version, deleted_at
+		FROM users
+		WHERE email = $1 AND ($2 OR deleted_at IS NULL)
+		ORDER BY deleted_at IS NOT NULL, deleted_at DESC
+		LIMIT 1
+	`
+
+	user, err := scanSQLiteUser(r.db.db.QueryRowContext(ctx, query, email, includeDeleted(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by email: %w", translateSQLiteError(err, "user"))
+	}
+	return user, nil
+}
+
+// UpdateUser implements UserRepository.
+func (r *sqliteUserRepository) UpdateUser(ctx context.Context, user *User) (*User, error) {
+	updated, err := r.update(ctx, r.db.db, user, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	*user = *updated
+	return user, nil
+}
+
+// update replaces the name and email of a live user, at user.Version when it is set.
+func (r *sqliteUserRepository) update(ctx context.Context, q sqliteDBTX, user *User, now time.Time) (*User, error) {
+	query := `
+		-- name: UpdateUser
+		UPDATE users
+		SET name = $1, email = $2, updated_at = $3, version = version + 1
+		WHERE id = $4 AND deleted_at IS NULL AND ($5 = 0 OR version = $5)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	updated, err := scanSQLiteUser(q.QueryRowContext(ctx, query, user.Name, user.Email, formatSQLiteTime(now), user.ID, user.Version))
+	if errors.Is(err, sql.ErrNoRows) && user.Version != 0 {
+		return nil, r.versionMismatch(ctx, q, user.ID)
+	}
+	if err != nil {
+		return nil, translateSQLiteError(err, "user")
+	}
+
+	return updated, nil
+}
+
+// PatchUser implements UserRepository.
+func (r *sqliteUserRepository) PatchUser(ctx context.Context, id uuid.UUID, patch UserPatch) (*User, error) {
+	if patch.IsZero() {
+		user, err := r.GetUserByID(ctx, id)
+		if err == nil && patch.Version != 0 && user.Version != patch.Version {
+			return nil, r.versionMismatch(ctx, r.db.db, id)
+		}
+		return user, err
+	}
+
+	var args sqlArgs
+	var assignments []string
+	if patch.Name != nil {
+		assignments = append(assignments, "name = "+args.add(*patch.Name))
+	}
+	if patch.Email != nil {
+		assignments = append(assignments, "email = "+args.add(*patch.Email))
+	}
+	assignments = append(assignments, "updated_at = "+args.add(time.Now()), "version = version + 1")
+
+	conditions := []string{"id = " + args.add(id), "deleted_at IS NULL"}
+	if patch.Version != 0 {
+		conditions = append(conditions, "version = "+args.add(patch.Version))
+	}
+
+	query := fmt.Sprintf(`
+		-- name: PatchUser
+		UPDATE users
+		SET %s
+		%s
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`, strings.Join(assignments, ", "), whereClause(conditions))
+
+	user, err := scanSQLiteUser(r.db.db.QueryRowContext(ctx, query, sqliteValues(args)...))
+	if errors.Is(err, sql.ErrNoRows) && patch.Version != 0 {
+		return nil, fmt.Errorf("failed to patch user: %w", r.versionMismatch(ctx, r.db.db, id))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch user: %w", translateSQLiteError(err, "user"))
+	}
+
+	return user, nil
+}
+
+// DeleteUser implements UserRepository.
+func (r *sqliteUserRepository) DeleteUser(ctx context.Context, id uuid.UUID, version int64) error {
+	if _, err := r.softDelete(ctx, r.db.db, UserRef{ID: id, Version: version}, time.Now()); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+// softDelete soft-deletes a live user, at ref.Version when it is set.
+func (r *sqliteUserRepository) softDelete(ctx context.Context, q sqliteDBTX, ref UserRef, now time.Time) (*User, error) {
+	query := `
+		-- name: DeleteUser
+		UPDATE users
+		SET deleted_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	user, err := scanSQLiteUser(q.QueryRowContext(ctx, query, formatSQLiteTime(now), ref.ID, ref.Version))
+	if errors.Is(err, sql.ErrNoRows) && ref.Version != 0 {
+		return nil, r.versionMismatch(ctx, q, ref.ID)
+	}
+	if err != nil {
+		return nil, translateSQLiteError(err, "user")
+	}
+
+	return user, nil
+}
+
+// RestoreUser implements UserRepository.
+func (r *sqliteUserRepository) RestoreUser(ctx context.Context, id uuid.UUID, version int64) (*User, error) {
+	query := `
+		-- name: RestoreUser
+		UPDATE users
+		SET deleted_at = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NOT NULL AND ($3 = 0 OR version = $3)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	user, err := scanSQLiteUser(r.db.db.QueryRowContext(ctx, query, formatSQLiteTime(time.Now()), id, version))
+	if errors.Is(err, sql.ErrNoRows) {
+		current, err := r.getByID(WithDeleted(ctx), r.db.db, id)
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("failed to restore user: %w", err)
+		case current.DeletedAt == nil:
+			return nil, newError(ErrConflict, "user is not deleted", nil)
+		default:
+			return nil, newError(ErrPreconditionFailed, "user has been modified since it was read", nil)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore user: %w", translateSQLiteError(err, "user"))
+	}
+
+	return user, nil
+}
+
+// versionMismatch explains why a conditional write affected no row: the user either does not exist, or moved to another version.
+func (r *sqliteUserRepository) versionMismatch(ctx context.Context, q sqliteDBTX, id uuid.UUID) error {
+	if _, err := r.getByID(ctx, q, id); err != nil {
+		return err
+	}
+	return newError(ErrPreconditionFailed, "user has been modified since it was read", nil)
+}
+
+// ListUsers implements UserRepository.
+func (r *sqliteUserRepository) ListUsers(ctx context.Context, params ListUsersParams) (*UserPage, error) {
+	sort := params.Sort
+	if len(sort) == 0 {
+		sort = DefaultUserSort
+	}
+	backward := params.Cursor != nil && params.Cursor.Backward
+
+	var args sqlArgs
+	conditions := sqliteUserConditions(ctx, params.Filter, &args)
+	if params.Cursor != nil {
+		condition, err := keysetCondition(sort, params.Cursor, &args)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	query := fmt.Sprintf(`
+		-- name: ListUsers
+		SELECT id, name, email, created_at, updated_at, version, deleted_at
+		FROM users
+		%s
+		%s
+		LIMIT %s
+	`, whereClause(conditions), orderByClause(sort, backward), args.add(params.Limit+1))
+
+	rows, err := r.db.db.QueryContext(ctx, query, sqliteValues(args)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", translateSQLiteError(err, "user"))
+	}
+	defer func() { _ = rows.Close() }()
+
+	var users []*User
+	for rows.Next() {
+		user, err := scanSQLiteUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate users: %w", err)
+	}
+
+	page := &UserPage{}
+	users, next, prev := paginate(users, params.Limit, backward, params.Cursor != nil)
+	page.Users = users
+	if next != nil {
+		page.NextCursor = userCursor(*next, sort, false)
+	}
+	if prev != nil {
+		page.PrevCursor = userCursor(*prev, sort, true)
+	}
+
+	// SQLite keeps no statistics to estimate from, counts are always exact
+	if params.Total != TotalNone {
+		var countArgs sqlArgs
+		countQuery := fmt.Sprintf(`
+			-- name: CountUsers
+			SELECT count(*) FROM users %s
+		`, whereClause(sqliteUserConditions(ctx, params.Filter, &countArgs)))
+
+		var total int64
+		if err := r.db.db.QueryRowContext(ctx, countQuery, sqliteValues(countArgs)...).Scan(&total); err != nil {
+			return nil, fmt.Errorf("failed to count users: %w", translateSQLiteError(err, "user"))
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// sqliteUserConditions returns the SQLite conditions of filter, like userConditions
+// LIKE ignores the case of ASCII letters in SQLite, and has no default escape character.
+func sqliteUserConditions(ctx context.Context, f UserFilter, args *sqlArgs) []string {
+	var conditions []string
+
+	if f.NamePrefix != "" {
+		conditions = append(conditions, "name LIKE "+args.add(escapeLike(f.NamePrefix)+"%")+` ESCAPE '\'`)
+	}
+	if f.EmailDomain != "" {
+		domain := strings.TrimPrefix(f.EmailDomain, "@")
+		conditions = append(conditions, "email LIKE "+args.add("%@"+escapeLike(domain))+` ESCAPE '\'`)
+	}
+
+	ranges := []struct {
+		column, operator string
+		value            *time.Time
+	}{
+		{"created_at", ">=", f.CreatedAfter},
+		{"created_at", "<", f.CreatedBefore},
+		{"updated_at", ">=", f.UpdatedAfter},
+		{"updated_at", "<", f.UpdatedBefore},
+	}
+	for _, r := range ranges {
+		if r.value != nil {
+			conditions = append(conditions, r.column+" "+r.operator+" "+args.add(*r.value))
+		}
+	}
+
+	if f.Query != "" {
+		contains, similar := args.add("%"+escapeLike(f.Query)+"%"), args.add(f.Query)
+		conditions = append(conditions, fmt.Sprintf(`(name LIKE %s ESCAPE '\' OR email LIKE %s ESCAPE '\' OR similarity(name, %s) >= %v)`,
+			contains, contains, similar, trigramSimilarityThreshold))
+	}
+
+	if !includeDeleted(ctx) {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	return conditions
+}
+
+// BatchCreateUsers implements UserRepository.
+func (r *sqliteUserRepository) BatchCreateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	now := time.Now()
+	return r.runBatch(ctx, len(users), atomic, func(tx *sql.Tx, i int) (*User, error) {
+		created, err := r.create(ctx, tx, users[i], now)
+		if err == nil {
+			*users[i] = *created
+		}
+		return created, err
+	})
+}
+
+// BatchUpdateUsers implements UserRepository.
+func (r *sqliteUserRepository) BatchUpdateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	now := time.Now()
+	return r.runBatch(ctx, len(users), atomic, func(tx *sql.Tx, i int) (*User, error) {
+		return r.update(ctx, tx, users[i], now)
+	})
+}
+
+// BatchDeleteUsers implements UserRepository.
+func (r *sqliteUserRepository) BatchDeleteUsers(ctx context.Context, refs []UserRef, atomic bool) ([]BatchResult, error) {
+	now := time.Now()
+	return r.runBatch(ctx, len(refs), atomic, func(tx *sql.Tx, i int) (*User, error) {
+		return r.softDelete(ctx, tx, refs[i], now)
+	})
+}
+
+// runBatch applies one write per item in a transaction, each in a savepoint rolled back when the item fails
+// In atomic mode, any failure rolls back the transaction instead, and the other items fail with ErrAborted.
+func (r *sqliteUserRepository) runBatch(ctx context.Context, n int, atomic bool, apply func(tx *sql.Tx, i int) (*User, error)) ([]BatchResult, error) {
+	tx, err := r.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin batch transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	results := make([]BatchResult, n)
+	failed := false
+	for i := range results {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+			return nil, fmt.Errorf("failed to run batch: %w", err)
+		}
+
+		user, err := apply(tx, i)
+		var repoErr *Error
+		switch {
+		case err == nil:
+			results[i].User = user
+		case errors.As(err, &repoErr):
+			results[i].Err = err
+			failed = true
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO batch_item"); err != nil {
+				return nil, fmt.Errorf("failed to run batch: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("failed to run batch: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "RELEASE batch_item"); err != nil {
+			return nil, fmt.Errorf("failed to run batch: %w", err)
+		}
+	}
+
+	if atomic && failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BatchResult{Err: errBatchAborted}
+			}
+		}
+		return results, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit batch: %w", translateSQLiteError(err, "user"))
+	}
+
+	return results, nil
+}
+
+// scanSQLiteUser scans a user row, parsing its timestamps.
+func scanSQLiteUser(row sqliteRow) (*User, error) {
+	var user User
+	var createdAt, updatedAt string
+	var deletedAt sql.NullString
+	if err := row.Scan(&user.ID, &user.Name, &user.Email, &createdAt, &updatedAt, &user.Version, &deletedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if user.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return nil, fmt.Errorf("invalid created_at: %w", err)
+	}
+	if user.UpdatedAt, err = parseSQLiteTime(updatedAt); err != nil {
+		return nil, fmt.Errorf("invalid updated_at: %w", err)
+	}
+	if deletedAt.Valid {
+		t, err := parseSQLiteTime(deletedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deleted_at: %w", err)
+		}
+		user.DeletedAt = &t
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+// queryNamePrefix marks the name of a query, following the sqlc convention: `-- name: GetUserByID`.
+const queryNamePrefix = "-- name:"
+
+// queryTraceContextKey is the context key of the query being traced.
+type queryTraceContextKey struct{}
+
+// queryTrace holds the state of a query between its start and its end.
+type queryTrace struct {
+	start time.Time
+	name  string
+	sql   string
+	host  string
+}
+
+// queryTracer logs every query executed by pgx with its duration, and warns about slow ones
+// This tracer demonstrates how to plug observability into the database layer.
+type queryTracer struct {
+	logger        *zerolog.Logger
+	slowThreshold time.Duration
+}
+
+// newQueryTracer creates a tracer logging through logger.
+// Queries lasting longer than slowThreshold are logged as warnings, a zero threshold disables them.
+func newQueryTracer(logger *zerolog.Logger, slowThreshold time.Duration) *queryTracer {
+	return &queryTracer{
+		logger:        logger,
+		slowThreshold: slowThreshold,
+	}
+}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (t *queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceContextKey{}, &queryTrace{
+		start: time.Now(),
+		name:  queryName(data.SQL),
+		sql:   data.SQL,
+		host:  conn.Config().Host,
+	})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceContextKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(trace.start)
+	slow := t.slowThreshold > 0 && duration >= t.slowThreshold
+
+	var event *zerolog.Event
+	msg := "Query executed"
+	switch {
+	case data.Err != nil:
+		event, msg = t.logger.Error().Err(data.Err), "Query failed"
+	case slow:
+		event, msg = t.logger.Warn(), "Slow query"
+	default:
+		event = t.logger.Debug()
+	}
+
+	event = event.
+		Str("query", trace.name).
+		Dur("duration", duration).
+		Int64("rows", data.CommandTag.RowsAffected()).
+		Str("host", trace.host)
+
+	// Only include the full statement when something needs investigating
+	if data.Err != nil || slow {
+		event = event.Str("sql", compactSQL(trace.sql))
+	}
+
+	event.Msg(msg)
+}
+
+// queryName extracts the name of a query from its `-- name:` comment.
+func queryName(sql string) string {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(line, queryNamePrefix); ok {
+			return strings.TrimSpace(name)
+		}
+
+		// The name comment must come first
+		break
+	}
+
+	return "unnamed"
+}
+
+// compactSQL collapses the whitespaces of a query to keep log lines short.
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
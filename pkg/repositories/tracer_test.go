@@ -0,0 +1,20 @@
+package repositories
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"\n\t\t-- name: GetUserByID\n\t\tSELECT 1": "GetUserByID",
+		"-- name:ListUsers\nSELECT 1":              "ListUsers",
+		"SELECT 1\n-- name: Late":                  "unnamed",
+		"":                                         "unnamed",
+	}
+
+	for sql, expected := range testCases {
+		if got := queryName(sql); got != expected {
+			t.Errorf("queryName(%q) = %q, expected %q", sql, got, expected)
+		}
+	}
+}
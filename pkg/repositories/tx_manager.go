@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// pgSerializationFailure and pgDeadlockDetected are the SQLSTATE codes of transient transaction conflicts.
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+
+	// txRetryBackoff is the delay before the first retry of a conflicting transaction, doubled on every retry up to txMaxRetryBackoff.
+	txRetryBackoff    = 10 * time.Millisecond
+	txMaxRetryBackoff = time.Second
+)
+
+// DBTX is the set of query methods shared by pgxpool.Pool and pgx.Tx
+// This interface lets repositories run the same queries inside or outside of a transaction.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// txContextKey is the context key of the current transaction.
+type txContextKey struct{}
+
+// TxFromContext returns the transaction stored in ctx by TxManager.WithinTx, if any.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// txHooksContextKey is the context key of the functions to run once the current transaction commits.
+type txHooksContextKey struct{}
+
+// txHooks holds the functions registered with afterCommit during a transaction.
+type txHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// afterCommit runs fn once the transaction of ctx commits, or right away outside of a transaction
+// Functions registered in a transaction which rolls back are never run.
+func afterCommit(ctx context.Context, fn func()) {
+	hooks, ok := ctx.Value(txHooksContextKey{}).(*txHooks)
+	if !ok {
+		fn()
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.fns = append(hooks.fns, fn)
+}
+
+// querier returns the transaction of ctx when there is one, and the primary pool otherwise
+// Every repository write goes through it, so that it transparently joins the current unit of work.
+func querier(ctx context.Context, db *Database) DBTX {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db.Pool()
+}
+
+// readQuerier is like querier, but routes reads made outside of a transaction to a replica.
+func readQuerier(ctx context.Context, db *Database) DBTX {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db.Reader(ctx)
+}
+
+// TxOption customizes a transaction started by TxManager.WithinTx.
+type TxOption func(*pgx.TxOptions)
+
+// WithIsolationLevel overrides the configured isolation level of a transaction.
+func WithIsolationLevel(level pgx.TxIsoLevel) TxOption {
+	return func(opts *pgx.TxOptions) {
+		opts.IsoLevel = level
+	}
+}
+
+// WithReadOnly starts a read-only transaction.
+func WithReadOnly() TxOption {
+	return func(opts *pgx.TxOptions) {
+		opts.AccessMode = pgx.ReadOnly
+	}
+}
+
+// txBeginner starts transactions, it is implemented by pgxpool.Pool.
+type txBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// TxManager runs units of work in database transactions
+// This service demonstrates how to share a transaction between repositories through the context.
+type TxManager struct {
+	db         *Database       `do:""`
+	logger     *zerolog.Logger `do:""`
+	beginner   txBeginner
+	isoLevel   pgx.TxIsoLevel
+	maxRetries int
+	backoff    Backoff
+}
+
+// NewTxManager creates a new TxManager configured from the database configuration
+// This function demonstrates how to initialize a service with dependencies using samber/do.
+func NewTxManager(injector do.Injector) (*TxManager, error) {
+	manager := do.MustInvokeStruct[*TxManager](injector)
+	cfg := do.MustInvoke[*config.Config](injector).Database
+
+	isoLevel, err := parseIsolationLevel(cfg.TxIsolation)
+	if err != nil {
+		return nil, err
+	}
+
+	manager.beginner = manager.db.Pool()
+	manager.isoLevel = isoLevel
+	manager.maxRetries = max(cfg.TxMaxRetries, 0)
+	manager.backoff = Backoff{Initial: txRetryBackoff, Max: txMaxRetryBackoff}
+
+	return manager, nil
+}
+
+// WithinTx runs fn in a transaction and commits it when fn returns nil
+// The transaction is stored in the context passed to fn, so that repositories use it transparently.
+// When ctx already holds a transaction, fn joins it instead of starting a new one.
+// Serialization failures and deadlocks are retried with backoff, so fn must be safe to run several times.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	txOptions := pgx.TxOptions{IsoLevel: m.isoLevel}
+	for _, opt := range opts {
+		opt(&txOptions)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := m.runTx(ctx, txOptions, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= m.maxRetries {
+			return err
+		}
+
+		delay := m.backoff.Delay(attempt + 1)
+		m.logger.Warn().Err(err).Int("attempt", attempt+1).Dur("delay", delay).Msg("Retrying conflicting transaction")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runTx runs a single attempt of a transaction, then the functions registered with afterCommit once it commits
+// The transaction is rolled back unless it was committed, including when fn panics, so that its connection is never left idle in transaction.
+func (m *TxManager) runTx(ctx context.Context, txOptions pgx.TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := m.beginner.BeginTx(ctx, txOptions)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		// Rolling back a committed transaction is a no-op reported as ErrTxClosed
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			m.logger.Error().Err(rbErr).Msg("Failed to rollback transaction")
+		}
+	}()
+
+	hooks := &txHooks{}
+	if err := fn(context.WithValue(context.WithValue(ctx, txContextKey{}, tx), txHooksContextKey{}, hooks)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, hook := range hooks.fns {
+		hook()
+	}
+
+	return nil
+}
+
+// withSavepoint runs fn in a savepoint of the transaction of ctx, released when fn returns nil and rolled back otherwise
+// Statements failing in fn then leave the transaction usable.
+func withSavepoint(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	outer, ok := TxFromContext(ctx)
+	if !ok {
+		return errors.New("savepoints require a transaction")
+	}
+
+	tx, err := outer.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin savepoint: %w", err)
+	}
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// isRetryableTxError reports whether err is a transient conflict worth retrying.
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected
+}
+
+// parseIsolationLevel converts a configured isolation level to its pgx value.
+// An empty level keeps the server default.
+func parseIsolationLevel(level string) (pgx.TxIsoLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(strings.ReplaceAll(level, "_", " "))) {
+	case "":
+		return "", nil
+	case "read committed":
+		return pgx.ReadCommitted, nil
+	case "repeatable read":
+		return pgx.RepeatableRead, nil
+	case "serializable":
+		return pgx.Serializable, nil
+	default:
+		return "", fmt.Errorf("unsupported transaction isolation level: %q", level)
+	}
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+)
+
+// fakeTx records whether it was committed or rolled back, like a pgx.Tx it can only be closed once.
+type fakeTx struct {
+	pgx.Tx
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	if tx.committed || tx.rolledBack {
+		return pgx.ErrTxClosed
+	}
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if tx.committed || tx.rolledBack {
+		return pgx.ErrTxClosed
+	}
+	tx.rolledBack = true
+	return nil
+}
+
+// fakeBeginner starts fake transactions, and records them with their options.
+type fakeBeginner struct {
+	txs     []*fakeTx
+	options []pgx.TxOptions
+}
+
+func (b *fakeBeginner) BeginTx(_ context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx := &fakeTx{}
+	b.txs = append(b.txs, tx)
+	b.options = append(b.options, txOptions)
+	return tx, nil
+}
+
+func newFakeTxManager(maxRetries int) (*TxManager, *fakeBeginner) {
+	logger := zerolog.Nop()
+	beginner := &fakeBeginner{}
+	manager := &TxManager{
+		logger:     &logger,
+		beginner:   beginner,
+		isoLevel:   pgx.RepeatableRead,
+		maxRetries: maxRetries,
+		backoff:    Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+	}
+	return manager, beginner
+}
+
+func TestWithinTx(t *testing.T) {
+	t.Parallel()
+
+	errFailed := errors.New("failed")
+	conflict := &pgconn.PgError{Code: pgSerializationFailure}
+	deadlock := &pgconn.PgError{Code: pgDeadlockDetected}
+
+	testCases := map[string]struct {
+		maxRetries int
+		// errs are returned by the successive attempts, the attempts after the last one succeed
+		errs      []error
+		wantErr   error
+		attempts  int
+		committed bool
+	}{
+		"committed":             {attempts: 1, committed: true},
+		"rolled back":           {errs: []error{errFailed}, wantErr: errFailed, attempts: 1},
+		"serialization failure": {maxRetries: 2, errs: []error{conflict, conflict}, attempts: 3, committed: true},
+		"deadlock":              {maxRetries: 1, errs: []error{deadlock}, attempts: 2, committed: true},
+		"retries exhausted":     {maxRetries: 1, errs: []error{conflict, conflict}, wantErr: conflict, attempts: 2},
+		"not retryable":         {maxRetries: 3, errs: []error{errFailed}, wantErr: errFailed, attempts: 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			manager, beginner := newFakeTxManager(tc.maxRetries)
+
+			attempt := 0
+			err := manager.WithinTx(context.Background(), func(ctx context.Context) error {
+				defer func() { attempt++ }()
+
+				if tx, ok := TxFromContext(ctx); !ok || tx != beginner.txs[attempt] {
+					t.Errorf("attempt %d does not hold its transaction", attempt)
+				}
+				if attempt < len(tc.errs) {
+					return tc.errs[attempt]
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("WithinTx() error = %v, want %v", err, tc.wantErr)
+			}
+			if len(beginner.txs) != tc.attempts {
+				t.Fatalf("started %d transactions, want %d", len(beginner.txs), tc.attempts)
+			}
+			for i, tx := range beginner.txs {
+				last := i == len(beginner.txs)-1
+				if want := last && tc.committed; tx.committed != want || tx.rolledBack == want {
+					t.Errorf("transaction %d committed = %v, rolled back = %v, want committed = %v", i, tx.committed, tx.rolledBack, want)
+				}
+				if beginner.options[i].IsoLevel != pgx.RepeatableRead {
+					t.Errorf("transaction %d isolation level = %q, want the configured one", i, beginner.options[i].IsoLevel)
+				}
+			}
+		})
+	}
+}
+
+func TestWithinTxJoinsOuterTransaction(t *testing.T) {
+	t.Parallel()
+
+	manager, beginner := newFakeTxManager(0)
+	outer := &fakeTx{}
+	ctx := context.WithValue(context.Background(), txContextKey{}, pgx.Tx(outer))
+
+	err := manager.WithinTx(ctx, func(ctx context.Context) error {
+		if tx, _ := TxFromContext(ctx); tx != outer {
+			t.Error("fn does not hold the outer transaction")
+		}
+		return nil
+	})
+
+	if err != nil || len(beginner.txs) != 0 {
+		t.Fatalf("WithinTx() = %v and started %d transactions, want to join the outer one", err, len(beginner.txs))
+	}
+	if outer.committed || outer.rolledBack {
+		t.Error("the outer transaction was closed, want its owner to close it")
+	}
+}
+
+func TestWithinTxRollsBackOnPanic(t *testing.T) {
+	t.Parallel()
+
+	manager, beginner := newFakeTxManager(0)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithinTx() recovered the panic, want it to propagate")
+			}
+		}()
+		_ = manager.WithinTx(context.Background(), func(context.Context) error {
+			panic("boom")
+		})
+	}()
+
+	if len(beginner.txs) != 1 || !beginner.txs[0].rolledBack {
+		t.Error("the transaction was not rolled back")
+	}
+}
+
+func TestWithinTxOptions(t *testing.T) {
+	t.Parallel()
+
+	manager, beginner := newFakeTxManager(0)
+
+	err := manager.WithinTx(context.Background(), func(context.Context) error { return nil }, WithIsolationLevel(pgx.Serializable), WithReadOnly())
+	if err != nil {
+		t.Fatalf("WithinTx() error = %v", err)
+	}
+
+	if opts := beginner.options[0]; opts.IsoLevel != pgx.Serializable || opts.AccessMode != pgx.ReadOnly {
+		t.Errorf("options = %+v, want a serializable read-only transaction", opts)
+	}
+}
+
+func TestParseIsolationLevel(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		level   pgx.TxIsoLevel
+		wantErr bool
+	}{
+		"":                {level: ""},
+		"read committed":  {level: pgx.ReadCommitted},
+		"READ_COMMITTED":  {level: pgx.ReadCommitted},
+		"repeatable read": {level: pgx.RepeatableRead},
+		" Serializable ":  {level: pgx.Serializable},
+		"read uncommited": {wantErr: true},
+		"snapshot":        {wantErr: true},
+	}
+
+	for raw, tc := range testCases {
+		level, err := parseIsolationLevel(raw)
+		if (err != nil) != tc.wantErr || level != tc.level {
+			t.Errorf("parseIsolationLevel(%q) = %q, %v, want %q (error: %v)", raw, level, err, tc.level, tc.wantErr)
+		}
+	}
+}
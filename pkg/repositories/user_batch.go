@@ -0,0 +1,249 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// BatchResult is the outcome of an item of a batch operation
+// Err is nil when the item succeeded, and holds a repository error otherwise.
+type BatchResult struct {
+	User *User
+	Err  error
+}
+
+// UserRef identifies a user, at a given version when Version is set.
+type UserRef struct {
+	ID      uuid.UUID
+	Version int64
+}
+
+// errBatchAborted is the error of the items rolled back because of another item.
+var errBatchAborted = newError(ErrAborted, "not applied because another item failed", nil)
+
+// BatchCreateUsers creates users with COPY, falling back to one INSERT per item when PostgreSQL rejects the COPY
+// In atomic mode, either every user is created or none is; otherwise the valid users are created.
+func (r *userRepository) BatchCreateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	// PostgreSQL stores timestamps with microsecond precision, the returned users must match the stored ones
+	now := time.Now().Truncate(time.Microsecond)
+	rows := make([][]any, len(users))
+	for i, user := range users {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate user ID: %w", err)
+		}
+
+		user.ID, user.CreatedAt, user.UpdatedAt, user.Version = id, now, now, 1
+		rows[i] = []any{user.ID, user.Name, user.Email, user.CreatedAt, user.UpdatedAt}
+	}
+
+	query := `
+		-- name: BatchCreateUser
+		INSERT INTO users (id, name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	var results []BatchResult
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// COPY is the fastest way to insert many rows, but a single invalid row fails it as a whole
+		err := r.copyUsers(ctx, rows, users)
+		if err == nil {
+			results = make([]BatchResult, len(users))
+			for i, user := range users {
+				results[i].User = user
+			}
+			return nil
+		}
+
+		// Only the rows rejected by PostgreSQL are worth inserting one by one, other failures are returned
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) {
+			return fmt.Errorf("failed to copy users: %w", err)
+		}
+
+		results, err = r.runBatch(ctx, len(users), atomic, EventUserCreated, func(batch *pgx.Batch, i int) {
+			batch.Queue(query, rows[i]...)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// copyUsers inserts rows with COPY in a savepoint, so that a failure does not abort the transaction of ctx
+// The user.created events of users are recorded along with them.
+func (r *userRepository) copyUsers(ctx context.Context, rows [][]any, users []*User) error {
+	return withSavepoint(ctx, func(tx pgx.Tx) error {
+		columns := []string{"id", "name", "email", "created_at", "updated_at"}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"users"}, columns, pgx.CopyFromRows(rows)); err != nil {
+			return err
+		}
+		return recordUserEvents(ctx, tx, EventUserCreated, users...)
+	})
+}
+
+// BatchUpdateUsers updates users in a single round trip
+// Users with a Version are only updated at that version, like UpdateUser.
+func (r *userRepository) BatchUpdateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error) {
+	query := `
+		-- name: BatchUpdateUser
+		UPDATE users
+		SET name = $1, email = $2, updated_at = $3, version = version + 1
+		WHERE id = $4 AND deleted_at IS NULL AND ($5::BIGINT = 0 OR version = $5)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	now := time.Now()
+	results, err := r.runBatch(ctx, len(users), atomic, EventUserUpdated, func(batch *pgx.Batch, i int) {
+		batch.Queue(query, users[i].Name, users[i].Email, now, users[i].ID, users[i].Version)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]UserRef, len(users))
+	for i, user := range users {
+		refs[i] = UserRef{ID: user.ID, Version: user.Version}
+	}
+
+	return r.explainMissing(ctx, results, refs), nil
+}
+
+// BatchDeleteUsers soft-deletes users in a single round trip
+// Users with a Version are only deleted at that version, like DeleteUser.
+func (r *userRepository) BatchDeleteUsers(ctx context.Context, refs []UserRef, atomic bool) ([]BatchResult, error) {
+	query := `
+		-- name: BatchDeleteUser
+		UPDATE users
+		SET deleted_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3::BIGINT = 0 OR version = $3)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	now := time.Now()
+	results, err := r.runBatch(ctx, len(refs), atomic, EventUserDeleted, func(batch *pgx.Batch, i int) {
+		batch.Queue(query, now, refs[i].ID, refs[i].Version)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.explainMissing(ctx, results, refs), nil
+}
+
+// runBatch runs one queued statement per item in a transaction, each returning the affected user
+// A failing statement aborts the whole pipeline, so it is retried without the failing item until every item succeeded or failed.
+// In atomic mode, the first failure rolls back the batch instead, and the other items fail with ErrAborted.
+// An event of eventType is recorded for every affected user, in the transaction of the batch.
+func (r *userRepository) runBatch(ctx context.Context, n int, atomic bool, eventType string, queue func(batch *pgx.Batch, i int)) ([]BatchResult, error) {
+	var results []BatchResult
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		results = make([]BatchResult, n)
+		pending := make([]int, n)
+		for i := range pending {
+			pending[i] = i
+		}
+
+		for len(pending) > 0 {
+			// Each pass runs in a savepoint, rolled back when an item fails
+			failed := -1
+			err := withSavepoint(ctx, func(tx pgx.Tx) error {
+				var failures int
+				var err error
+				failed, failures, err = r.sendBatch(ctx, tx, pending, results, queue)
+				if err != nil {
+					return err
+				}
+				if failed >= 0 || (atomic && failures > 0) {
+					return errBatchAborted
+				}
+
+				affected := make([]*User, 0, len(pending))
+				for _, i := range pending {
+					if results[i].Err == nil {
+						affected = append(affected, results[i].User)
+					}
+				}
+				return recordUserEvents(ctx, tx, eventType, affected...)
+			})
+
+			switch {
+			case errors.Is(err, errBatchAborted) && atomic:
+				for i := range results {
+					if results[i].Err == nil {
+						results[i] = BatchResult{Err: errBatchAborted}
+					}
+				}
+				return nil
+			case errors.Is(err, errBatchAborted):
+				pending = append(pending[:failed:failed], pending[failed+1:]...)
+			case err != nil:
+				return err
+			default:
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run batch: %w", translateError(err, "user"))
+	}
+
+	return results, nil
+}
+
+// sendBatch runs the statements of the pending items, and stores their results
+// It returns the position in pending of the item failing the pipeline, -1 if none, and the number of items affecting no row.
+func (r *userRepository) sendBatch(ctx context.Context, tx pgx.Tx, pending []int, results []BatchResult, queue func(batch *pgx.Batch, i int)) (int, int, error) {
+	batch := &pgx.Batch{}
+	for _, i := range pending {
+		queue(batch, i)
+	}
+
+	batchResults := tx.SendBatch(ctx, batch)
+
+	failures := 0
+	for k, i := range pending {
+		var user User
+		err := batchResults.QueryRow().Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt)
+
+		var pgErr *pgconn.PgError
+		switch {
+		case err == nil:
+			results[i] = BatchResult{User: &user}
+		case errors.As(err, &pgErr):
+			// The server skips the following statements, and rolls back the previous ones
+			results[i] = BatchResult{Err: translateError(err, "user")}
+			_ = batchResults.Close()
+			return k, failures, nil
+		case errors.Is(err, pgx.ErrNoRows):
+			results[i] = BatchResult{Err: translateError(err, "user")}
+			failures++
+		default:
+			_ = batchResults.Close()
+			return -1, failures, err
+		}
+	}
+
+	return -1, failures, batchResults.Close()
+}
+
+// explainMissing tells apart, for the conditional writes of a batch affecting no row, missing users from users at another version.
+func (r *userRepository) explainMissing(ctx context.Context, results []BatchResult, refs []UserRef) []BatchResult {
+	for i, result := range results {
+		if refs[i].Version != 0 && errors.Is(result.Err, ErrNotFound) {
+			results[i].Err = r.versionMismatch(ctx, refs[i].ID)
+		}
+	}
+	return results
+}
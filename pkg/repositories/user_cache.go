@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CacheStats holds the counters of a cache, for observability.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// CacheStatsReporter is implemented by the repositories serving reads from a cache.
+type CacheStatsReporter interface {
+	CacheStats() CacheStats
+}
+
+// userCacheEntry is a cached user, indexed both by ID and by email.
+type userCacheEntry struct {
+	user    User
+	expires time.Time
+}
+
+// userCache is a bounded LRU cache of users with a time to live
+// Each user is stored once, and can be looked up either by ID or by email.
+type userCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List
+	byID    map[uuid.UUID]*list.Element
+	byEmail map[string]*list.Element
+	// generation is incremented on every invalidation, so that loads started before it are not cached
+	generation uint64
+	stats      CacheStats
+}
+
+// newUserCache creates a cache holding at most size users for ttl.
+func newUserCache(size int, ttl time.Duration) *userCache {
+	return &userCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		byID:    map[uuid.UUID]*list.Element{},
+		byEmail: map[string]*list.Element{},
+	}
+}
+
+// getByID returns a copy of the cached user with the given ID.
+func (c *userCache) getByID(id uuid.UUID) (*User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hit(c.byID[id])
+}
+
+// getByEmail returns a copy of the cached user with the given email.
+func (c *userCache) getByEmail(email string) (*User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hit(c.byEmail[email])
+}
+
+// hit records a lookup of elem, which is nil on a miss, and moves it to the front of the LRU list.
+func (c *userCache) hit(elem *list.Element) (*User, bool) {
+	if elem != nil && c.now().After(elem.Value.(*userCacheEntry).expires) {
+		c.remove(elem)
+		elem = nil
+	}
+	if elem == nil {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.order.MoveToFront(elem)
+	user := elem.Value.(*userCacheEntry).user
+	return &user, true
+}
+
+// begin returns the current generation, to be passed to add once the user is loaded.
+func (c *userCache) begin() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// add caches a copy of user, unless something was invalidated since generation was read
+// The loaded user may predate that invalidation, and caching it would serve stale data until it expires.
+func (c *userCache) add(user *User, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if elem, ok := c.byID[user.ID]; ok {
+		c.remove(elem)
+	}
+	if elem, ok := c.byEmail[user.Email]; ok {
+		c.remove(elem)
+	}
+
+	elem := c.order.PushFront(&userCacheEntry{user: *user, expires: c.now().Add(c.ttl)})
+	c.byID[user.ID] = elem
+	c.byEmail[user.Email] = elem
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate removes the users with the given IDs.
+func (c *userCache) invalidate(ids ...uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, id := range ids {
+		if elem, ok := c.byID[id]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+// invalidateEmail removes the user with the given email.
+func (c *userCache) invalidateEmail(email string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.byEmail[email]; ok {
+		c.remove(elem)
+	}
+}
+
+// purge removes every user, e.g. when invalidations may have been missed.
+func (c *userCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.order.Init()
+	clear(c.byID)
+	clear(c.byEmail)
+}
+
+// remove unlinks elem from the list and both indexes.
+func (c *userCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*userCacheEntry)
+	delete(c.byID, entry.user.ID)
+	delete(c.byEmail, entry.user.Email)
+}
+
+// snapshot returns the current counters.
+func (c *userCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
@@ -0,0 +1,187 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+const (
+	// userEventChannel is the PostgreSQL channel on which the outbox trigger notifies the user events.
+	userEventChannel = "user_events"
+
+	// userEventSubscriberBuffer is the number of events queued for a subscriber, which is dropped when it falls behind.
+	userEventSubscriberBuffer = 256
+
+	// defaultEventReplaySize is used when no replay buffer size is configured.
+	defaultEventReplaySize = 1000
+)
+
+// UserEventSubscription is a subscription to the user events of a UserEventStream.
+type UserEventSubscription struct {
+	// Replay holds the buffered events following the last event ID of the subscriber.
+	Replay []Event
+	// Resumed reports whether the last event ID was found in the replay buffer
+	// When it was not, events may have been missed and the subscriber should reload the users.
+	Resumed bool
+	// Events receives the events as they are notified
+	// It is closed when the subscriber falls behind, or when the stream stops or reconnects.
+	Events <-chan Event
+}
+
+// UserEventStream broadcasts the user events notified by PostgreSQL to its subscribers
+// Every instance of the API listens to every event, and keeps the latest ones so that subscribers can resume after a disconnection.
+type UserEventStream struct {
+	db          *Database       `do:""`
+	logger      *zerolog.Logger `do:""`
+	size        int
+	mu          sync.Mutex
+	buffer      []Event
+	subscribers map[chan Event]struct{}
+	listener    *listener
+}
+
+// NewUserEventStream creates the user event stream and starts listening in the background
+// Events are recorded in the outbox, so they are only streamed by the PostgreSQL driver.
+func NewUserEventStream(injector do.Injector) (*UserEventStream, error) {
+	appConfig := do.MustInvoke[*config.Config](injector)
+
+	driver, err := databaseDriver(appConfig)
+	if err != nil {
+		return nil, err
+	}
+	if driver != DriverPostgres {
+		return nil, fmt.Errorf("%w: the %s database driver does not stream user events", ErrUnsupportedDriver, driver)
+	}
+
+	stream := do.MustInvokeStruct[*UserEventStream](injector)
+	stream.size = appConfig.Database.EventReplaySize
+	if stream.size <= 0 {
+		stream.size = defaultEventReplaySize
+	}
+	stream.subscribers = map[chan Event]struct{}{}
+
+	// Notifications sent while disconnected are lost, so the replay buffer is reset every time the listener connects
+	stream.listener = newListener(stream.db, userEventChannel, stream.logger, stream.reset, stream.receive)
+
+	return stream, nil
+}
+
+// Subscribe subscribes to the events following lastEventID, or to the next events when it is empty
+// The returned function ends the subscription.
+func (s *UserEventStream) Subscribe(lastEventID string) (*UserEventSubscription, func()) {
+	events := make(chan Event, userEventSubscriberBuffer)
+	subscription := &UserEventSubscription{Events: events, Resumed: lastEventID == ""}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The replay and the registration happen under the same lock, so that no event falls in between
+	if id, err := uuid.Parse(lastEventID); err == nil {
+		for i := len(s.buffer) - 1; i >= 0; i-- {
+			if s.buffer[i].ID == id {
+				subscription.Replay = append([]Event(nil), s.buffer[i+1:]...)
+				subscription.Resumed = true
+				break
+			}
+		}
+	}
+	s.subscribers[events] = struct{}{}
+
+	return subscription, func() { s.unsubscribe(events) }
+}
+
+// unsubscribe ends a subscription, unless the stream already dropped it.
+func (s *UserEventStream) unsubscribe(events chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[events]; ok {
+		delete(s.subscribers, events)
+		close(events)
+	}
+}
+
+// publish buffers an event and sends it to the subscribers, dropping those which fell behind.
+func (s *UserEventStream) publish(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buffer = append(s.buffer, event)
+	if len(s.buffer) > s.size {
+		s.buffer = s.buffer[len(s.buffer)-s.size:]
+	}
+
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+			// The subscriber resumes from the replay buffer once it reconnects
+			delete(s.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// reset empties the replay buffer and drops every subscriber
+// Subscribers reconnecting with an event ID are then told that they may have missed events.
+func (s *UserEventStream) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buffer = nil
+	for events := range s.subscribers {
+		delete(s.subscribers, events)
+		close(events)
+	}
+}
+
+// receive publishes a notified event, reading it back from the outbox when it was too large to be notified.
+func (s *UserEventStream) receive(ctx context.Context, payload string) {
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		s.logger.Warn().Str("payload", payload).Msg("Ignoring invalid user event notification")
+		return
+	}
+
+	// Large events are only notified by ID
+	if event.Type == "" {
+		if err := s.load(ctx, &event); err != nil {
+			s.logger.Warn().Err(err).Str("event_id", event.ID.String()).Msg("Failed to load user event")
+			return
+		}
+	}
+
+	s.publish(event)
+}
+
+// load reads an event back from the outbox.
+func (s *UserEventStream) load(ctx context.Context, event *Event) error {
+	query := `
+		-- name: GetOutboxEvent
+		SELECT id, type, aggregate_id, payload, created_at
+		FROM outbox
+		WHERE id = $1
+	`
+
+	err := s.db.Pool().QueryRow(ctx, query, event.ID).Scan(&event.ID, &event.Type, &event.AggregateID, &event.Payload, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to get outbox event: %w", translateError(err, "event"))
+	}
+	return nil
+}
+
+// Shutdown stops the listener and ends every subscription.
+func (s *UserEventStream) Shutdown() error {
+	if s.listener != nil {
+		s.listener.stop()
+	}
+	s.reset()
+	return nil
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+func TestUserEventStreamSubscribe(t *testing.T) {
+	t.Parallel()
+
+	// The stream is not listening, events are published by hand
+	stream := &UserEventStream{size: 3, subscribers: map[chan Event]struct{}{}}
+
+	events := make([]Event, 4)
+	for i := range events {
+		events[i] = Event{ID: uuid.New(), Type: EventUserUpdated}
+		stream.publish(events[i])
+	}
+
+	testCases := map[string]struct {
+		lastEventID string
+		resumed     bool
+		replay      []Event
+	}{
+		"new subscriber":     {lastEventID: "", resumed: true},
+		"resumed":            {lastEventID: events[1].ID.String(), resumed: true, replay: events[2:]},
+		"up to date":         {lastEventID: events[3].ID.String(), resumed: true},
+		"no longer buffered": {lastEventID: events[0].ID.String(), resumed: false},
+		"invalid event id":   {lastEventID: "first", resumed: false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			subscription, unsubscribe := stream.Subscribe(tc.lastEventID)
+			defer unsubscribe()
+
+			if subscription.Resumed != tc.resumed {
+				t.Errorf("Resumed = %v, want %v", subscription.Resumed, tc.resumed)
+			}
+			if len(subscription.Replay) != len(tc.replay) {
+				t.Fatalf("Replay = %v, want %v", subscription.Replay, tc.replay)
+			}
+			for i := range tc.replay {
+				if subscription.Replay[i].ID != tc.replay[i].ID {
+					t.Errorf("Replay[%d] = %v, want %v", i, subscription.Replay[i].ID, tc.replay[i].ID)
+				}
+			}
+		})
+	}
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		subscription, unsubscribe := stream.Subscribe("")
+		defer unsubscribe()
+
+		for range userEventSubscriberBuffer + 1 {
+			stream.publish(Event{ID: uuid.New(), Type: EventUserCreated})
+		}
+
+		received := 0
+		for range subscription.Events {
+			received++
+		}
+		if received != userEventSubscriberBuffer {
+			t.Errorf("received %d events, want the subscriber to be dropped once its %d buffered events are read", received, userEventSubscriberBuffer)
+		}
+	})
+}
+
+// TestUserEventStream runs against the database of TEST_DATABASE_URL, sequentially since it shares the users table.
+func TestUserEventStream(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	injector := do.New(Package)
+	do.ProvideValue(injector, &config.Config{Database: config.DatabaseConfig{Driver: DriverPostgres, URL: url}})
+	do.ProvideValue(injector, &logger)
+	t.Cleanup(func() { injector.Shutdown() })
+
+	if _, err := do.MustInvoke[*Migrator](injector).Up(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if _, err := do.MustInvoke[*Database](injector).Pool().Exec(ctx, `TRUNCATE users, outbox`); err != nil {
+		t.Fatalf("failed to empty tables: %v", err)
+	}
+
+	stream := do.MustInvoke[*UserEventStream](injector)
+	users := do.MustInvoke[UserRepository](injector)
+
+	// Subscriptions are dropped once the listener starts listening, so users are created until an event is received
+	deadline := time.Now().Add(5 * time.Second)
+	for attempt := 0; time.Now().Before(deadline); attempt++ {
+		subscription, unsubscribe := stream.Subscribe("")
+
+		user, err := users.CreateUser(ctx, &User{Name: "John", Email: fmt.Sprintf("john%d@example.com", attempt)})
+		if err != nil {
+			unsubscribe()
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+
+		select {
+		case event, ok := <-subscription.Events:
+			unsubscribe()
+			if !ok {
+				continue
+			}
+			if event.Type != EventUserCreated || event.AggregateID != user.ID || len(event.Payload) == 0 {
+				t.Errorf("event = %+v, want the user.created event of the user", event)
+			}
+			return
+		case <-time.After(time.Second):
+			unsubscribe()
+		}
+	}
+
+	t.Fatal("no event was received")
+}
@@ -0,0 +1,261 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserFilter restricts a users listing
+// Every field is optional, and the set ones are combined.
+type UserFilter struct {
+	// NamePrefix matches users whose name starts with it, ignoring case.
+	NamePrefix string
+	// EmailDomain matches users whose email address belongs to it, ignoring case.
+	EmailDomain string
+	// CreatedAfter and UpdatedAfter are inclusive, CreatedBefore and UpdatedBefore are exclusive.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// Query searches the name and email, tolerating typos in the name.
+	Query string
+}
+
+// conditions returns the SQL conditions of the filter, appending their values to args.
+func (f UserFilter) conditions(args *sqlArgs) []string {
+	var conditions []string
+
+	if f.NamePrefix != "" {
+		conditions = append(conditions, "name ILIKE "+args.add(escapeLike(f.NamePrefix)+"%"))
+	}
+	if f.EmailDomain != "" {
+		domain := strings.TrimPrefix(f.EmailDomain, "@")
+		conditions = append(conditions, "email ILIKE "+args.add("%@"+escapeLike(domain)))
+	}
+
+	ranges := []struct {
+		column, operator string
+		value            *time.Time
+	}{
+		{"created_at", ">=", f.CreatedAfter},
+		{"created_at", "<", f.CreatedBefore},
+		{"updated_at", ">=", f.UpdatedAfter},
+		{"updated_at", "<", f.UpdatedBefore},
+	}
+	for _, r := range ranges {
+		if r.value != nil {
+			conditions = append(conditions, r.column+" "+r.operator+" "+args.add(*r.value))
+		}
+	}
+
+	if f.Query != "" {
+		contains, similar := args.add("%"+escapeLike(f.Query)+"%"), args.add(f.Query)
+		conditions = append(conditions, fmt.Sprintf("(name ILIKE %s OR email ILIKE %s OR name %% %s)", contains, contains, similar))
+	}
+
+	return conditions
+}
+
+// deletedContextKey is the context key including soft-deleted users in reads.
+type deletedContextKey struct{}
+
+// WithDeleted returns a context whose reads include soft-deleted users
+// Writes ignore soft-deleted users regardless, they must be restored first.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedContextKey{}, true)
+}
+
+// includeDeleted reports whether ctx includes soft-deleted users in reads.
+func includeDeleted(ctx context.Context) bool {
+	included, _ := ctx.Value(deletedContextKey{}).(bool)
+	return included
+}
+
+// userConditions returns the SQL conditions of filter, hiding soft-deleted users unless ctx includes them.
+func userConditions(ctx context.Context, filter UserFilter, args *sqlArgs) []string {
+	conditions := filter.conditions(args)
+	if !includeDeleted(ctx) {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	return conditions
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// whereClause joins conditions in a WHERE clause, which is empty without conditions.
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// UserSortField is a column users can be sorted by.
+type UserSortField string
+
+const (
+	UserSortName      UserSortField = "name"
+	UserSortEmail     UserSortField = "email"
+	UserSortCreatedAt UserSortField = "created_at"
+	UserSortUpdatedAt UserSortField = "updated_at"
+)
+
+// UserSort sorts users by a field.
+type UserSort struct {
+	Field UserSortField
+	Desc  bool
+}
+
+// DefaultUserSort lists the most recent users first.
+var DefaultUserSort = []UserSort{{Field: UserSortCreatedAt, Desc: true}}
+
+// ParseUserSort parses a comma separated list of sort fields, each prefixed by `-` for a descending order
+// For example `-created_at,name` lists the most recent users first, then by name.
+func ParseUserSort(s string) ([]UserSort, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultUserSort, nil
+	}
+
+	var sort []UserSort
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		name, desc := strings.CutPrefix(part, "-")
+		field := UserSortField(strings.TrimPrefix(name, "+"))
+
+		switch field {
+		case UserSortName, UserSortEmail, UserSortCreatedAt, UserSortUpdatedAt:
+		default:
+			return nil, newError(ErrInvalid, fmt.Sprintf("invalid sort field %q", part), nil)
+		}
+
+		if slices.ContainsFunc(sort, func(s UserSort) bool { return s.Field == field }) {
+			return nil, newError(ErrInvalid, fmt.Sprintf("duplicate sort field %q", field), nil)
+		}
+
+		sort = append(sort, UserSort{Field: field, Desc: desc})
+	}
+
+	return sort, nil
+}
+
+// FormatUserSort returns the canonical representation of sort, as parsed by ParseUserSort.
+func FormatUserSort(sort []UserSort) string {
+	parts := make([]string, len(sort))
+	for i, s := range sort {
+		parts[i] = string(s.Field)
+		if s.Desc {
+			parts[i] = "-" + parts[i]
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// orderByClause returns the ORDER BY clause of sort, reversed for backward pages
+// The ID breaks ties, in the direction of the last field.
+func orderByClause(sort []UserSort, backward bool) string {
+	parts := make([]string, 0, len(sort)+1)
+	for _, s := range sort {
+		parts = append(parts, string(s.Field)+" "+sortDirection(s.Desc, backward))
+	}
+	parts = append(parts, "id "+sortDirection(sort[len(sort)-1].Desc, backward))
+
+	return "ORDER BY " + strings.Join(parts, ", ")
+}
+
+// sortDirection returns the SQL direction of a sort field.
+func sortDirection(desc, backward bool) string {
+	if desc != backward {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// keysetCondition returns the condition selecting the users after cursor in the sort order, or before it for backward cursors
+// Fields sorted in different directions cannot use a row comparison, so the condition is expanded:
+// (a > $1) OR (a = $1 AND b < $2) OR (a = $1 AND b = $2 AND id < $3).
+func keysetCondition(sort []UserSort, cursor *Cursor, args *sqlArgs) (string, error) {
+	if cursor.Sort != FormatUserSort(sort) || len(cursor.Values) != len(sort) {
+		return "", newError(ErrInvalid, "pagination cursor does not match the sort order", nil)
+	}
+
+	columns := make([]string, 0, len(sort)+1)
+	operators := make([]string, 0, len(sort)+1)
+	placeholders := make([]string, 0, len(sort)+1)
+	for i, s := range sort {
+		value, err := parseSortValue(s.Field, cursor.Values[i])
+		if err != nil {
+			return "", err
+		}
+
+		columns = append(columns, string(s.Field))
+		operators = append(operators, keysetOperator(s.Desc, cursor.Backward))
+		placeholders = append(placeholders, args.add(value))
+	}
+	columns = append(columns, "id")
+	operators = append(operators, keysetOperator(sort[len(sort)-1].Desc, cursor.Backward))
+	placeholders = append(placeholders, args.add(cursor.ID))
+
+	// A row comparison makes the most of a matching index, when every field is sorted in the same direction
+	if !slices.ContainsFunc(operators, func(op string) bool { return op != operators[0] }) {
+		return "(" + strings.Join(columns, ", ") + ") " + operators[0] + " (" + strings.Join(placeholders, ", ") + ")", nil
+	}
+
+	alternatives := make([]string, len(columns))
+	for i := range columns {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, columns[j]+" = "+placeholders[j])
+		}
+		terms = append(terms, columns[i]+" "+operators[i]+" "+placeholders[i])
+		alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", nil
+}
+
+// keysetOperator returns the comparison selecting the items after a cursor.
+func keysetOperator(desc, backward bool) string {
+	if desc != backward {
+		return "<"
+	}
+	return ">"
+}
+
+// userCursor returns the cursor pointing at user in the sort order.
+func userCursor(user *User, sort []UserSort, backward bool) *Cursor {
+	values := make([]string, len(sort))
+	for i, s := range sort {
+		switch s.Field {
+		case UserSortName:
+			values[i] = user.Name
+		case UserSortEmail:
+			values[i] = user.Email
+		case UserSortCreatedAt:
+			values[i] = user.CreatedAt.Format(time.RFC3339Nano)
+		case UserSortUpdatedAt:
+			values[i] = user.UpdatedAt.Format(time.RFC3339Nano)
+		}
+	}
+
+	return &Cursor{Sort: FormatUserSort(sort), Values: values, ID: user.ID, Backward: backward}
+}
+
+// parseSortValue converts a cursor value to the type of its sort field.
+func parseSortValue(field UserSortField, value string) (any, error) {
+	switch field {
+	case UserSortCreatedAt, UserSortUpdatedAt:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, newError(ErrInvalid, "invalid pagination cursor", err)
+		}
+		return t, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseUserSort(t *testing.T) {
+	t.Parallel()
+
+	sort, err := ParseUserSort(" -created_at, name ")
+	if err != nil {
+		t.Fatalf("ParseUserSort() error = %v", err)
+	}
+	if got := FormatUserSort(sort); got != "-created_at,name" {
+		t.Errorf("FormatUserSort() = %q", got)
+	}
+
+	if sort, _ := ParseUserSort(""); FormatUserSort(sort) != "-created_at" {
+		t.Errorf("ParseUserSort(\"\") = %v, want the default sort", sort)
+	}
+
+	for _, raw := range []string{"password", "name,-name", "-", "name;DROP TABLE users"} {
+		if _, err := ParseUserSort(raw); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ParseUserSort(%q) error = %v, want ErrInvalid", raw, err)
+		}
+	}
+}
+
+func TestUserFilterConditions(t *testing.T) {
+	t.Parallel()
+
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := UserFilter{
+		NamePrefix:   "jo_",
+		EmailDomain:  "@example.com",
+		CreatedAfter: &after,
+		Query:        "100%",
+	}
+
+	var args sqlArgs
+	got := whereClause(filter.conditions(&args))
+	want := `WHERE name ILIKE $1 AND email ILIKE $2 AND created_at >= $3 AND (name ILIKE $4 OR email ILIKE $4 OR name % $5)`
+	if got != want {
+		t.Errorf("conditions = %q, want %q", got, want)
+	}
+
+	wantArgs := []any{`jo\_%`, "%@example.com", after, `%100\%%`, "100%"}
+	if len(args) != len(wantArgs) {
+		t.Fatalf("args = %v, want %v", args, wantArgs)
+	}
+	for i := range args {
+		if args[i] != wantArgs[i] {
+			t.Errorf("arg %d = %v, want %v", i+1, args[i], wantArgs[i])
+		}
+	}
+
+	if got := whereClause(UserFilter{}.conditions(&args)); got != "" {
+		t.Errorf("empty filter = %q, want no clause", got)
+	}
+}
+
+func TestUserConditionsHideDeleted(t *testing.T) {
+	t.Parallel()
+
+	var args sqlArgs
+	if got, want := whereClause(userConditions(context.Background(), UserFilter{}, &args)), "WHERE deleted_at IS NULL"; got != want {
+		t.Errorf("conditions = %q, want %q", got, want)
+	}
+	if got := whereClause(userConditions(WithDeleted(context.Background()), UserFilter{}, &args)); got != "" {
+		t.Errorf("conditions with deleted = %q, want no clause", got)
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	t.Parallel()
+
+	user := &User{
+		ID:        uuid.New(),
+		Name:      "John",
+		CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC),
+	}
+
+	testCases := map[string]struct {
+		sort     string
+		backward bool
+		where    string
+		order    string
+	}{
+		"default": {
+			sort:  "-created_at",
+			where: "(created_at, id) < ($1, $2)",
+			order: "ORDER BY created_at DESC, id DESC",
+		},
+		"default backward": {
+			sort:     "-created_at",
+			backward: true,
+			where:    "(created_at, id) > ($1, $2)",
+			order:    "ORDER BY created_at ASC, id ASC",
+		},
+		"mixed directions": {
+			sort:  "-created_at,name",
+			where: "((created_at < $1) OR (created_at = $1 AND name > $2) OR (created_at = $1 AND name = $2 AND id > $3))",
+			order: "ORDER BY created_at DESC, name ASC, id ASC",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sort, err := ParseUserSort(tc.sort)
+			if err != nil {
+				t.Fatalf("ParseUserSort() error = %v", err)
+			}
+
+			var args sqlArgs
+			where, err := keysetCondition(sort, userCursor(user, sort, tc.backward), &args)
+			if err != nil {
+				t.Fatalf("keysetCondition() error = %v", err)
+			}
+			if where != tc.where {
+				t.Errorf("keysetCondition() = %q, want %q", where, tc.where)
+			}
+			if !args[0].(time.Time).Equal(user.CreatedAt) {
+				t.Errorf("first arg = %v, want %v", args[0], user.CreatedAt)
+			}
+			if order := orderByClause(sort, tc.backward); order != tc.order {
+				t.Errorf("orderByClause() = %q, want %q", order, tc.order)
+			}
+		})
+	}
+
+	// Cursors are bound to their sort order
+	byName, _ := ParseUserSort("name")
+	if _, err := keysetCondition(DefaultUserSort, userCursor(user, byName, false), &sqlArgs{}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("keysetCondition() error = %v, want ErrInvalid", err)
+	}
+}
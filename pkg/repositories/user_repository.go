@@ -4,66 +4,160 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
 	"github.com/samber/do/v2"
 )
 
 // User represents a user model
 // This struct demonstrates how to define domain models for data access.
 type User struct {
-	ID        int64     `json:"id"`
+	ID        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Version is incremented on every write, for optimistic concurrency control.
+	Version int64 `json:"version"`
+	// DeletedAt is set once the user has been soft-deleted, and cleared when it is restored.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // UserRepository defines the interface for user data access operations
 // This interface demonstrates how to define contracts for repository pattern.
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *User) (*User, error)
-	GetUserByID(ctx context.Context, id int64) (*User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
 	UpdateUser(ctx context.Context, user *User) (*User, error)
-	DeleteUser(ctx context.Context, id int64) error
-	ListUsers(ctx context.Context, limit, offset int) ([]*User, error)
+	PatchUser(ctx context.Context, id uuid.UUID, patch UserPatch) (*User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID, version int64) error
+	RestoreUser(ctx context.Context, id uuid.UUID, version int64) (*User, error)
+	ListUsers(ctx context.Context, params ListUsersParams) (*UserPage, error)
+	BatchCreateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error)
+	BatchUpdateUsers(ctx context.Context, users []*User, atomic bool) ([]BatchResult, error)
+	BatchDeleteUsers(ctx context.Context, refs []UserRef, atomic bool) ([]BatchResult, error)
+}
+
+// UserPatch holds the fields of a partial user update
+// Nil fields are left unchanged, and a non-zero Version makes the update conditional.
+type UserPatch struct {
+	Name    *string
+	Email   *string
+	Version int64
+}
+
+// IsZero reports whether the patch changes no field.
+func (p UserPatch) IsZero() bool {
+	return p.Name == nil && p.Email == nil
+}
+
+// ListUsersParams holds the filters, sort order and pagination of a users listing
+// Users are listed from the most recent to the oldest unless Sort says otherwise.
+type ListUsersParams struct {
+	Filter UserFilter
+	Sort   []UserSort
+	Limit  int
+	Cursor *Cursor
+	Total  TotalMode
+}
+
+// UserPage is a page of users, with the cursors of the previous and next pages
+// Cursors are nil when there is no page in that direction, and Total is nil unless it was requested.
+type UserPage struct {
+	Users      []*User
+	NextCursor *Cursor
+	PrevCursor *Cursor
+	Total      *int64
 }
 
 // userRepository implements the UserRepository interface
 // This struct demonstrates how to implement repository pattern with dependency injection.
+// Every write records its domain events in the outbox table, in the transaction of the write.
+// Writes go through TxManager.WithinTx, so that they get the same isolation level and retries whether or not the caller holds a transaction.
 type userRepository struct {
-	db *pgxpool.Pool `do:""`
+	db *Database  `do:""`
+	tx *TxManager `do:""`
 }
 
-// NewUserRepository creates a new UserRepository instance
+// NewUserRepository creates a new UserRepository instance for the configured `database.driver`
 // This function demonstrates how to initialize a repository with database dependency.
+// Only the database of the chosen driver is invoked, so that PostgreSQL is never connected to by the other drivers.
 func NewUserRepository(injector do.Injector) (UserRepository, error) {
-	// Get database pool from the injector
-	db := do.MustInvoke[*Database](injector)
+	appConfig := do.MustInvoke[*config.Config](injector)
+	cfg := appConfig.Database
+
+	driver, err := databaseDriver(appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo UserRepository
+	switch driver {
+	case DriverMemory:
+		repo = newMemoryUserRepository()
+	case DriverSQLite:
+		repo = &sqliteUserRepository{db: do.MustInvoke[*SQLiteDatabase](injector)}
+	default:
+		// Get database pool from the injector
+		repo = &userRepository{db: do.MustInvoke[*Database](injector), tx: do.MustInvoke[*TxManager](injector)}
+	}
 
-	return &userRepository{db: db.Pool()}, nil
+	if cfg.CacheSize <= 0 {
+		return repo, nil
+	}
+
+	// Hot users are served from memory, see cachedUserRepository
+	cached := newCachedUserRepository(repo, cfg.CacheSize, time.Duration(cfg.CacheTTL)*time.Second, do.MustInvoke[*zerolog.Logger](injector))
+	if cfg.CacheNotify {
+		if driver != DriverPostgres {
+			return nil, fmt.Errorf("database.cache_notify requires the %s database driver", DriverPostgres)
+		}
+		cached.listen(do.MustInvoke[*Database](injector))
+	}
+
+	return cached, nil
 }
 
 // CreateUser creates a new user in the database
 // This method demonstrates how to implement CREATE operation with dependency injection.
 func (r *userRepository) CreateUser(ctx context.Context, user *User) (*User, error) {
 	query := `
-		INSERT INTO users (name, email, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, name, email, created_at, updated_at
+		-- name: CreateUser
+		INSERT INTO users (id, name, email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
 	`
 
+	// UUIDv7 identifiers are time-ordered, which keeps the primary key index compact
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate user ID: %w", err)
+	}
+
 	now := time.Now()
+	user.ID = id
 	user.CreatedAt = now
 	user.UpdatedAt = now
 
-	err := r.db.QueryRow(ctx, query, user.Name, user.Email, user.CreatedAt, user.UpdatedAt).Scan(
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt,
-	)
+	// The user.created event is committed along with the user
+	err = r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		q := querier(ctx, r.db)
+		err := q.QueryRow(ctx, query, user.ID, user.Name, user.Email, user.CreatedAt, user.UpdatedAt).Scan(
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return recordUserEvents(ctx, q, EventUserCreated, user)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, fmt.Errorf("failed to create user: %w", translateError(err, "user"))
 	}
 
 	return user, nil
@@ -71,19 +165,20 @@ func (r *userRepository) CreateUser(ctx context.Context, user *User) (*User, err
 
 // GetUserByID retrieves a user by ID
 // This method demonstrates how to implement READ operation with dependency injection.
-func (r *userRepository) GetUserByID(ctx context.Context, id int64) (*User, error) {
+func (r *userRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	query := `
-		SELECT id, name, email, created_at, updated_at
+		-- name: GetUserByID
+		SELECT id, name, email, created_at, updated_at, version, deleted_at
 		FROM users
-		WHERE id = $1
+		WHERE id = $1 AND ($2 OR deleted_at IS NULL)
 	`
 
 	var user User
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt,
+	err := readQuerier(ctx, r.db).QueryRow(ctx, query, id, includeDeleted(ctx)).Scan(
+		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+		return nil, fmt.Errorf("failed to get user by ID: %w", translateError(err, "user"))
 	}
 
 	return &user, nil
@@ -93,81 +188,233 @@ func (r *userRepository) GetUserByID(ctx context.Context, id int64) (*User, erro
 // This method demonstrates how to implement READ operation with dependency injection.
 func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, name, email, created_at, updated_at
+		-- name: GetUserByEmail
+		SELECT id, name, email, created_at, updated_at, version, deleted_at
 		FROM users
-		WHERE email = $1
+		WHERE email = $1 AND ($2 OR deleted_at IS NULL)
 	`
 
 	var user User
-	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt,
+	err := readQuerier(ctx, r.db).QueryRow(ctx, query, email, includeDeleted(ctx)).Scan(
+		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by email: %w", err)
+		return nil, fmt.Errorf("failed to get user by email: %w", translateError(err, "user"))
 	}
 
 	return &user, nil
 }
 
 // UpdateUser updates an existing user
-// This method demonstrates how to implement UPDATE operation with dependency injection.
+// When user.Version is set, the update only applies to that version and fails with ErrPreconditionFailed otherwise.
 func (r *userRepository) UpdateUser(ctx context.Context, user *User) (*User, error) {
 	query := `
+		-- name: UpdateUser
 		UPDATE users
-		SET name = $1, email = $2, updated_at = $3
-		WHERE id = $4
-		RETURNING id, name, email, created_at, updated_at
+		SET name = $1, email = $2, updated_at = $3, version = version + 1
+		WHERE id = $4 AND deleted_at IS NULL AND ($5::BIGINT = 0 OR version = $5)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
 	`
 
+	expected := user.Version
 	user.UpdatedAt = time.Now()
 
-	err := r.db.QueryRow(ctx, query, user.Name, user.Email, user.UpdatedAt, user.ID).Scan(
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt,
-	)
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		q := querier(ctx, r.db)
+		err := q.QueryRow(ctx, query, user.Name, user.Email, user.UpdatedAt, user.ID, expected).Scan(
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return recordUserEvents(ctx, q, EventUserUpdated, user)
+	})
+	if errors.Is(err, pgx.ErrNoRows) && expected != 0 {
+		return nil, fmt.Errorf("failed to update user: %w", r.versionMismatch(ctx, user.ID))
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to update user: %w", err)
+		return nil, fmt.Errorf("failed to update user: %w", translateError(err, "user"))
 	}
 
 	return user, nil
 }
 
-// DeleteUser deletes a user by ID
-// This method demonstrates how to implement DELETE operation with dependency injection.
-func (r *userRepository) DeleteUser(ctx context.Context, id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
+// PatchUser updates the fields set in patch, leaving the other columns untouched
+// This method demonstrates how to build a column-selective UPDATE safely.
+func (r *userRepository) PatchUser(ctx context.Context, id uuid.UUID, patch UserPatch) (*User, error) {
+	// Nothing to write, the current state is read from the primary to include the latest changes
+	if patch.IsZero() {
+		user, err := r.GetUserByID(WithPrimary(ctx), id)
+		if err == nil && patch.Version != 0 && user.Version != patch.Version {
+			return nil, r.versionMismatch(ctx, id)
+		}
+		return user, err
+	}
+
+	var args sqlArgs
+	var assignments []string
+	if patch.Name != nil {
+		assignments = append(assignments, "name = "+args.add(*patch.Name))
+	}
+	if patch.Email != nil {
+		assignments = append(assignments, "email = "+args.add(*patch.Email))
+	}
+	assignments = append(assignments, "updated_at = "+args.add(time.Now()), "version = version + 1")
+
+	conditions := []string{"id = " + args.add(id), "deleted_at IS NULL"}
+	if patch.Version != 0 {
+		conditions = append(conditions, "version = "+args.add(patch.Version))
+	}
+
+	query := fmt.Sprintf(`
+		-- name: PatchUser
+		UPDATE users
+		SET %s
+		%s
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`, strings.Join(assignments, ", "), whereClause(conditions))
 
-	result, err := r.db.Exec(ctx, query, id)
+	var user User
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		q := querier(ctx, r.db)
+		err := q.QueryRow(ctx, query, args...).Scan(
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return recordUserEvents(ctx, q, EventUserUpdated, &user)
+	})
+	if errors.Is(err, pgx.ErrNoRows) && patch.Version != 0 {
+		return nil, fmt.Errorf("failed to patch user: %w", r.versionMismatch(ctx, id))
+	}
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return nil, fmt.Errorf("failed to patch user: %w", translateError(err, "user"))
 	}
 
-	if result.RowsAffected() == 0 {
-		return errors.New("user not found")
+	return &user, nil
+}
+
+// DeleteUser soft-deletes a user by ID, hiding it from reads until it is restored
+// A non-zero version makes the deletion conditional, failing with ErrPreconditionFailed when the user moved to another version.
+func (r *userRepository) DeleteUser(ctx context.Context, id uuid.UUID, version int64) error {
+	query := `
+		-- name: DeleteUser
+		UPDATE users
+		SET deleted_at = $1, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NULL AND ($3::BIGINT = 0 OR version = $3)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
+	`
+
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		q := querier(ctx, r.db)
+		var user User
+		err := q.QueryRow(ctx, query, time.Now(), id, version).Scan(
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return recordUserEvents(ctx, q, EventUserDeleted, &user)
+	})
+	if errors.Is(err, pgx.ErrNoRows) && version != 0 {
+		return fmt.Errorf("failed to delete user: %w", r.versionMismatch(ctx, id))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", translateError(err, "user"))
 	}
 
 	return nil
 }
 
-// ListUsers retrieves a list of users with pagination
-// This method demonstrates how to implement LIST operation with dependency injection.
-func (r *userRepository) ListUsers(ctx context.Context, limit, offset int) ([]*User, error) {
+// RestoreUser brings a soft-deleted user back
+// Restoring fails with ErrConflict when the user is not deleted, or when its email address was taken by another user in the meantime.
+func (r *userRepository) RestoreUser(ctx context.Context, id uuid.UUID, version int64) (*User, error) {
 	query := `
-		SELECT id, name, email, created_at, updated_at
-		FROM users
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
+		-- name: RestoreUser
+		UPDATE users
+		SET deleted_at = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NOT NULL AND ($3::BIGINT = 0 OR version = $3)
+		RETURNING id, name, email, created_at, updated_at, version, deleted_at
 	`
 
-	rows, err := r.db.Query(ctx, query, limit, offset)
+	// Restored users are live again, consumers see them updated
+	var user User
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		q := querier(ctx, r.db)
+		err := q.QueryRow(ctx, query, time.Now(), id, version).Scan(
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return recordUserEvents(ctx, q, EventUserUpdated, &user)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		current, err := r.GetUserByID(WithDeleted(WithPrimary(ctx)), id)
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("failed to restore user: %w", err)
+		case current.DeletedAt == nil:
+			return nil, newError(ErrConflict, "user is not deleted", nil)
+		default:
+			return nil, newError(ErrPreconditionFailed, "user has been modified since it was read", nil)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore user: %w", translateError(err, "user"))
+	}
+
+	return &user, nil
+}
+
+// versionMismatch explains why a conditional write affected no row: the user either does not exist, or moved to another version.
+func (r *userRepository) versionMismatch(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.GetUserByID(WithPrimary(ctx), id); err != nil {
+		return err
+	}
+	return newError(ErrPreconditionFailed, "user has been modified since it was read", nil)
+}
+
+// ListUsers retrieves a page of users with keyset pagination
+// This method demonstrates how to implement LIST operation with dependency injection.
+func (r *userRepository) ListUsers(ctx context.Context, params ListUsersParams) (*UserPage, error) {
+	sort := params.Sort
+	if len(sort) == 0 {
+		sort = DefaultUserSort
+	}
+	backward := params.Cursor != nil && params.Cursor.Backward
+
+	var args sqlArgs
+	conditions := userConditions(ctx, params.Filter, &args)
+	if params.Cursor != nil {
+		condition, err := keysetCondition(sort, params.Cursor, &args)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	// One extra row tells whether another page follows, previous pages are read in reverse order
+	query := fmt.Sprintf(`
+		-- name: ListUsers
+		SELECT id, name, email, created_at, updated_at, version, deleted_at
+		FROM users
+		%s
+		%s
+		LIMIT %s
+	`, whereClause(conditions), orderByClause(sort, backward), args.add(params.Limit+1))
+
+	rows, err := readQuerier(ctx, r.db).Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
+		return nil, fmt.Errorf("failed to list users: %w", translateError(err, "user"))
 	}
 	defer rows.Close()
 
 	var users []*User
 	for rows.Next() {
 		var user User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 		users = append(users, &user)
@@ -177,5 +424,76 @@ func (r *userRepository) ListUsers(ctx context.Context, limit, offset int) ([]*U
 		return nil, fmt.Errorf("failed to iterate users: %w", err)
 	}
 
-	return users, nil
+	page := &UserPage{}
+	users, next, prev := paginate(users, params.Limit, backward, params.Cursor != nil)
+	page.Users = users
+	if next != nil {
+		page.NextCursor = userCursor(*next, sort, false)
+	}
+	if prev != nil {
+		page.PrevCursor = userCursor(*prev, sort, true)
+	}
+
+	if params.Total != TotalNone {
+		total, err := r.countUsers(ctx, params.Filter, params.Total)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// countUsers counts the users matching filter, either exactly or from the query planner estimates.
+func (r *userRepository) countUsers(ctx context.Context, filter UserFilter, mode TotalMode) (int64, error) {
+	var args sqlArgs
+	where := whereClause(userConditions(ctx, filter, &args))
+
+	if mode == TotalEstimated {
+		estimate, err := r.estimateUsers(ctx, where, args)
+		if err != nil {
+			return 0, err
+		}
+
+		// Falls back to an exact count when the plan carries no estimate
+		if estimate >= 0 {
+			return estimate, nil
+		}
+	}
+
+	query := fmt.Sprintf(`
+		-- name: CountUsers
+		SELECT count(*) FROM users %s
+	`, where)
+
+	var total int64
+	if err := readQuerier(ctx, r.db).QueryRow(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", translateError(err, "user"))
+	}
+
+	return total, nil
+}
+
+// estimateUsers returns the planner estimate of the number of users matching where, or -1 when the plan has none
+// Listings always filter out the deleted users, so the estimate comes from the query plan rather than the table statistics.
+func (r *userRepository) estimateUsers(ctx context.Context, where string, args sqlArgs) (int64, error) {
+	query := fmt.Sprintf(`
+		-- name: EstimateUsers
+		EXPLAIN (FORMAT JSON) SELECT 1 FROM users %s
+	`, where)
+
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := readQuerier(ctx, r.db).QueryRow(ctx, query, args...).Scan(&plans); err != nil {
+		return 0, fmt.Errorf("failed to estimate users: %w", translateError(err, "user"))
+	}
+	if len(plans) == 0 {
+		return -1, nil
+	}
+
+	return int64(plans[0].Plan.Rows), nil
 }
@@ -0,0 +1,361 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// userRepositoryBackend creates an empty UserRepository of a driver.
+type userRepositoryBackend struct {
+	name   string
+	driver string
+	// shared backends hold a single users table, so their tests cannot run in parallel.
+	shared bool
+	setup  func(t *testing.T, cfg *config.Config)
+}
+
+// userRepositoryBackends lists every driver, PostgreSQL being only tested when TEST_DATABASE_URL is set.
+var userRepositoryBackends = []userRepositoryBackend{
+	{name: "memory", driver: DriverMemory},
+	{name: "cached memory", driver: DriverMemory, setup: func(t *testing.T, cfg *config.Config) {
+		cfg.Database.CacheSize = 100
+	}},
+	{name: "sqlite", driver: DriverSQLite, setup: func(t *testing.T, cfg *config.Config) {
+		cfg.Database.Path = ":memory:"
+	}},
+	{name: "postgres", driver: DriverPostgres, shared: true, setup: func(t *testing.T, cfg *config.Config) {
+		cfg.Database.URL = os.Getenv("TEST_DATABASE_URL")
+		if cfg.Database.URL == "" {
+			t.Skip("TEST_DATABASE_URL is not set")
+		}
+	}},
+}
+
+// newTestUserRepository wires the UserRepository of backend through repositories.Package, like the API does.
+func newTestUserRepository(t *testing.T, backend userRepositoryBackend) UserRepository {
+	t.Helper()
+
+	cfg := &config.Config{Database: config.DatabaseConfig{Driver: backend.driver}}
+	if backend.setup != nil {
+		backend.setup(t, cfg)
+	}
+
+	logger := zerolog.Nop()
+	injector := do.New(Package)
+	do.ProvideValue(injector, cfg)
+	do.ProvideValue(injector, &logger)
+	t.Cleanup(func() { injector.Shutdown() })
+
+	if backend.driver == DriverPostgres {
+		if _, err := do.MustInvoke[*Migrator](injector).Up(context.Background()); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+		if _, err := do.MustInvoke[*Database](injector).Pool().Exec(context.Background(), `TRUNCATE users`); err != nil {
+			t.Fatalf("failed to empty users: %v", err)
+		}
+	}
+
+	return do.MustInvoke[UserRepository](injector)
+}
+
+// TestUserRepositoryConformance verifies the UserRepository contract against every driver.
+func TestUserRepositoryConformance(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]func(t *testing.T, repo UserRepository){
+		"unique email":     testUserRepositoryUniqueEmail,
+		"soft delete":      testUserRepositorySoftDelete,
+		"optimistic locks": testUserRepositoryVersions,
+		"pagination":       testUserRepositoryPagination,
+		"filters":          testUserRepositoryFilters,
+		"batches":          testUserRepositoryBatches,
+	}
+
+	for _, backend := range userRepositoryBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			if !backend.shared {
+				t.Parallel()
+			}
+
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					if !backend.shared {
+						t.Parallel()
+					}
+					test(t, newTestUserRepository(t, backend))
+				})
+			}
+		})
+	}
+}
+
+func testUserRepositoryUniqueEmail(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+
+	john, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if john.Version != 1 || john.CreatedAt.IsZero() || !john.CreatedAt.Equal(john.UpdatedAt) {
+		t.Errorf("CreateUser() = %+v, want a new user at version 1", john)
+	}
+
+	_, err = repo.CreateUser(ctx, &User{Name: "Johnny", Email: "john@example.com"})
+	if !errors.Is(err, ErrConflict) || ErrorDetail(err) != "email already exists" {
+		t.Fatalf("CreateUser() with a taken email error = %v, want email conflict", err)
+	}
+
+	jane, err := repo.CreateUser(ctx, &User{Name: "Jane", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	_, err = repo.UpdateUser(ctx, &User{ID: jane.ID, Name: "Jane", Email: "john@example.com"})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("UpdateUser() with a taken email error = %v, want ErrConflict", err)
+	}
+
+	got, err := repo.GetUserByEmail(ctx, "john@example.com")
+	if err != nil || got.ID != john.ID || !got.CreatedAt.Equal(john.CreatedAt) {
+		t.Errorf("GetUserByEmail() = %+v, %v, want %+v", got, err, john)
+	}
+}
+
+func testUserRepositorySoftDelete(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+
+	john, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	// Deleted users release their email, and cannot be restored while it is taken
+	if err := repo.DeleteUser(ctx, john.ID, 0); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if err := repo.DeleteUser(ctx, john.ID, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteUser() of a deleted user error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetUserByID(ctx, john.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByID() of a deleted user error = %v, want ErrNotFound", err)
+	}
+	if user, err := repo.GetUserByID(WithDeleted(ctx), john.ID); err != nil || user.DeletedAt == nil || user.Version != 2 {
+		t.Errorf("GetUserByID() with deleted = %+v, %v, want the deleted user at version 2", user, err)
+	}
+	if _, err := repo.UpdateUser(ctx, &User{ID: john.ID, Name: "John", Email: "john@example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateUser() of a deleted user error = %v, want ErrNotFound", err)
+	}
+
+	johnny, err := repo.CreateUser(ctx, &User{Name: "Johnny", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() with a released email error = %v", err)
+	}
+	if _, err := repo.RestoreUser(ctx, john.ID, 0); !errors.Is(err, ErrConflict) {
+		t.Errorf("RestoreUser() with a taken email error = %v, want ErrConflict", err)
+	}
+
+	if err := repo.DeleteUser(ctx, johnny.ID, 0); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	if _, err := repo.RestoreUser(ctx, john.ID, 1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("RestoreUser() at a stale version error = %v, want ErrPreconditionFailed", err)
+	}
+	restored, err := repo.RestoreUser(ctx, john.ID, 2)
+	if err != nil || restored.DeletedAt != nil || restored.Version != 3 {
+		t.Fatalf("RestoreUser() = %+v, %v, want a live user at version 3", restored, err)
+	}
+	if _, err := repo.RestoreUser(ctx, john.ID, 0); !errors.Is(err, ErrConflict) {
+		t.Errorf("RestoreUser() of a live user error = %v, want ErrConflict", err)
+	}
+}
+
+func testUserRepositoryVersions(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+
+	john, err := repo.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	name := "Johnny"
+	patched, err := repo.PatchUser(ctx, john.ID, UserPatch{Name: &name, Version: 1})
+	if err != nil || patched.Name != name || patched.Email != "john@example.com" || patched.Version != 2 {
+		t.Fatalf("PatchUser() = %+v, %v, want the renamed user at version 2", patched, err)
+	}
+
+	if _, err := repo.PatchUser(ctx, john.ID, UserPatch{Name: &name, Version: 1}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("PatchUser() at a stale version error = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := repo.UpdateUser(ctx, &User{ID: john.ID, Name: "John", Email: "john@example.com", Version: 1}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("UpdateUser() at a stale version error = %v, want ErrPreconditionFailed", err)
+	}
+	if err := repo.DeleteUser(ctx, john.ID, 1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("DeleteUser() at a stale version error = %v, want ErrPreconditionFailed", err)
+	}
+
+	updated, err := repo.UpdateUser(ctx, &User{ID: john.ID, Name: "John", Email: "john@example.com", Version: 2})
+	if err != nil || updated.Version != 3 {
+		t.Errorf("UpdateUser() = %+v, %v, want version 3", updated, err)
+	}
+
+	missing := *john
+	missing.ID[0] ^= 0xff
+	if _, err := repo.UpdateUser(ctx, &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateUser() of a missing user error = %v, want ErrNotFound", err)
+	}
+}
+
+func testUserRepositoryPagination(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+	for i := range 5 {
+		// Every other user shares a name, so that the ID breaks ties
+		if _, err := repo.CreateUser(ctx, &User{Name: fmt.Sprintf("user %d", i%2), Email: fmt.Sprintf("user%d@example.com", i)}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	sort := []UserSort{{Field: UserSortName, Desc: true}}
+	params := ListUsersParams{Sort: sort, Limit: 2, Total: TotalExact}
+
+	var forward []string
+	var last *UserPage
+	for {
+		page, err := repo.ListUsers(ctx, params)
+		if err != nil {
+			t.Fatalf("ListUsers() error = %v", err)
+		}
+		if page.Total == nil || *page.Total != 5 {
+			t.Errorf("Total = %v, want 5", page.Total)
+		}
+		for _, user := range page.Users {
+			forward = append(forward, user.Email)
+		}
+		last = page
+		if page.NextCursor == nil {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+
+	// Ties are broken by ID in the direction of the last field, newest first
+	want := []string{"user3@example.com", "user1@example.com", "user4@example.com", "user2@example.com", "user0@example.com"}
+	if fmt.Sprint(forward) != fmt.Sprint(want) {
+		t.Fatalf("forward pages = %v, want %v", forward, want)
+	}
+
+	// Going back from the last page returns the previous one
+	params.Cursor = last.PrevCursor
+	page, err := repo.ListUsers(ctx, params)
+	if err != nil {
+		t.Fatalf("ListUsers() backward error = %v", err)
+	}
+	if len(page.Users) != 2 || page.Users[0].Email != "user4@example.com" || page.Users[1].Email != "user2@example.com" {
+		t.Errorf("backward page = %v, want user4 and user2", page.Users)
+	}
+	if page.PrevCursor == nil || page.NextCursor == nil {
+		t.Errorf("backward page cursors = %v, %v, want both", page.PrevCursor, page.NextCursor)
+	}
+
+	params.Cursor = &Cursor{Sort: "name", Values: []string{"user 0"}}
+	if _, err := repo.ListUsers(ctx, params); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ListUsers() with a cursor of another sort error = %v, want ErrInvalid", err)
+	}
+}
+
+func testUserRepositoryFilters(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+	for _, user := range []*User{
+		{Name: "John Smith", Email: "john@example.com"},
+		{Name: "Jane Doe", Email: "jane@example.org"},
+		{Name: "Johanna_Brown", Email: "johanna@example.com"},
+	} {
+		if _, err := repo.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	testCases := map[string]struct {
+		filter UserFilter
+		want   []string
+	}{
+		"name prefix":     {filter: UserFilter{NamePrefix: "jo"}, want: []string{"johanna@example.com", "john@example.com"}},
+		"escaped prefix":  {filter: UserFilter{NamePrefix: "Johanna_"}, want: []string{"johanna@example.com"}},
+		"wildcard prefix": {filter: UserFilter{NamePrefix: "J_"}, want: nil},
+		"email domain":    {filter: UserFilter{EmailDomain: "@EXAMPLE.org"}, want: []string{"jane@example.org"}},
+		"search":          {filter: UserFilter{Query: "brown"}, want: []string{"johanna@example.com"}},
+		"search typo":     {filter: UserFilter{Query: "Jhon Smith"}, want: []string{"john@example.com"}},
+		"combined":        {filter: UserFilter{NamePrefix: "j", EmailDomain: "example.com", Query: "john"}, want: []string{"john@example.com"}},
+	}
+
+	for name, tc := range testCases {
+		page, err := repo.ListUsers(ctx, ListUsersParams{Filter: tc.filter, Sort: []UserSort{{Field: UserSortEmail}}, Limit: 10, Total: TotalExact})
+		if err != nil {
+			t.Fatalf("%s: ListUsers() error = %v", name, err)
+		}
+
+		var got []string
+		for _, user := range page.Users {
+			got = append(got, user.Email)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) || *page.Total != int64(len(tc.want)) {
+			t.Errorf("%s: ListUsers() = %v (total %d), want %v", name, got, *page.Total, tc.want)
+		}
+	}
+}
+
+func testUserRepositoryBatches(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+
+	users := []*User{{Name: "John", Email: "john@example.com"}, {Name: "Johnny", Email: "john@example.com"}}
+	results, err := repo.BatchCreateUsers(ctx, users, true)
+	if err != nil {
+		t.Fatalf("BatchCreateUsers() error = %v", err)
+	}
+	if !errors.Is(results[0].Err, ErrAborted) || !errors.Is(results[1].Err, ErrConflict) {
+		t.Errorf("atomic results = %+v, want aborted and conflict", results)
+	}
+	if _, err := repo.GetUserByEmail(ctx, "john@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByEmail() after a failed atomic batch error = %v, want ErrNotFound", err)
+	}
+
+	users = []*User{{Name: "John", Email: "john@example.com"}, {Name: "Johnny", Email: "john@example.com"}, {Name: "Jane", Email: "jane@example.com"}}
+	results, err = repo.BatchCreateUsers(ctx, users, false)
+	if err != nil {
+		t.Fatalf("BatchCreateUsers() error = %v", err)
+	}
+	if results[0].Err != nil || !errors.Is(results[1].Err, ErrConflict) || results[2].Err != nil {
+		t.Fatalf("results = %+v, want created, conflict and created", results)
+	}
+	john, jane := results[0].User, results[2].User
+
+	updates := []*User{{ID: john.ID, Name: "John", Email: "john@example.org", Version: 1}, {ID: jane.ID, Name: "Jane", Email: "jane@example.org", Version: 7}}
+	results, err = repo.BatchUpdateUsers(ctx, updates, false)
+	if err != nil {
+		t.Fatalf("BatchUpdateUsers() error = %v", err)
+	}
+	if results[0].Err != nil || results[0].User.Version != 2 || !errors.Is(results[1].Err, ErrPreconditionFailed) {
+		t.Errorf("update results = %+v, want updated and precondition failed", results)
+	}
+
+	results, err = repo.BatchDeleteUsers(ctx, []UserRef{{ID: john.ID, Version: 2}, {ID: jane.ID}}, true)
+	if err != nil {
+		t.Fatalf("BatchDeleteUsers() error = %v", err)
+	}
+	for i, result := range results {
+		if result.Err != nil || result.User.DeletedAt == nil {
+			t.Errorf("delete result %d = %+v, want a deleted user", i, result)
+		}
+	}
+
+	page, err := repo.ListUsers(ctx, ListUsersParams{Limit: 10, Total: TotalExact})
+	if err != nil || len(page.Users) != 0 || *page.Total != 0 {
+		t.Errorf("ListUsers() after deleting every user = %+v, %v, want no users", page, err)
+	}
+}
@@ -0,0 +1,431 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// Statuses of a webhook delivery.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// EventTypes lists the types of the domain events, which webhooks may subscribe to.
+var EventTypes = []string{EventUserCreated, EventUserUpdated, EventUserDeleted}
+
+// Webhook is a subscription of a partner URL to the domain events.
+type Webhook struct {
+	ID  uuid.UUID
+	URL string
+	// EventTypes filters the delivered events, every event is delivered when it is empty.
+	EventTypes []string
+	// Secret signs the deliveries, it is generated when left empty on creation.
+	Secret    string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookDelivery is the delivery of an event to a webhook, with the outcome of its last attempt.
+type WebhookDelivery struct {
+	ID        uuid.UUID
+	WebhookID uuid.UUID
+	EventID   uuid.UUID
+	EventType string
+	// Payload is the request body, holding the event and the user as `data`.
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus *int
+	LastError      *string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// WebhookDispatch is a delivery claimed by a dispatcher, with the webhook it goes to.
+type WebhookDispatch struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt is the outcome of a delivery attempt.
+type WebhookAttempt struct {
+	// Status is the status of the delivery after the attempt.
+	Status string
+	// ResponseStatus is the HTTP status of the response, zero when none was received.
+	ResponseStatus int
+	Error          string
+	// RetryIn is the delay before the next attempt of a pending delivery.
+	RetryIn time.Duration
+}
+
+// WebhookRepository defines the interface for webhook subscriptions and their deliveries
+// It publishes the events relayed from the outbox by enqueuing a delivery to every active webhook matching them.
+type WebhookRepository interface {
+	EventPublisher
+	CreateWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*WebhookDelivery, error)
+	RedeliverWebhookDelivery(ctx context.Context, webhookID, deliveryID uuid.UUID) (*WebhookDelivery, error)
+	// ClaimWebhookDeliveries returns pending deliveries due for an attempt, hidden from the other dispatchers for lease.
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDispatch, error)
+	RecordWebhookAttempt(ctx context.Context, deliveryID uuid.UUID, attempt WebhookAttempt) error
+}
+
+// webhookRepository implements the WebhookRepository interface on PostgreSQL.
+type webhookRepository struct {
+	db *Database `do:""`
+}
+
+// NewWebhookRepository creates a new WebhookRepository
+// Webhooks are fed by the outbox, so they are only supported by the PostgreSQL driver.
+func NewWebhookRepository(injector do.Injector) (WebhookRepository, error) {
+	driver, err := databaseDriver(do.MustInvoke[*config.Config](injector))
+	if err != nil {
+		return nil, err
+	}
+	if driver != DriverPostgres {
+		return nil, fmt.Errorf("%w: the %s database driver does not support webhooks", ErrUnsupportedDriver, driver)
+	}
+
+	return do.MustInvokeStruct[*webhookRepository](injector), nil
+}
+
+// generateWebhookSecret returns a random signing secret.
+func generateWebhookSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// CreateWebhook creates a new webhook, generating its secret when none is set.
+func (r *webhookRepository) CreateWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error) {
+	query := `
+		-- name: CreateWebhook
+		INSERT INTO webhooks (id, url, event_types, secret, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id, url, event_types, secret, active, created_at, updated_at
+	`
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook ID: %w", err)
+	}
+	if webhook.Secret == "" {
+		if webhook.Secret, err = generateWebhookSecret(); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+	}
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+
+	var created Webhook
+	err = querier(ctx, r.db).QueryRow(ctx, query, id, webhook.URL, webhook.EventTypes, webhook.Secret, webhook.Active, time.Now()).Scan(
+		&created.ID, &created.URL, &created.EventTypes, &created.Secret, &created.Active, &created.CreatedAt, &created.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", translateError(err, "webhook"))
+	}
+
+	return &created, nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+func (r *webhookRepository) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	query := `
+		-- name: GetWebhook
+		SELECT id, url, event_types, secret, active, created_at, updated_at
+		FROM webhooks
+		WHERE id = $1
+	`
+
+	var webhook Webhook
+	err := querier(ctx, r.db).QueryRow(ctx, query, id).Scan(
+		&webhook.ID, &webhook.URL, &webhook.EventTypes, &webhook.Secret, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", translateError(err, "webhook"))
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooks retrieves every webhook, from the oldest to the most recent
+// Partners hold a handful of subscriptions, so they are not paginated.
+func (r *webhookRepository) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	query := `
+		-- name: ListWebhooks
+		SELECT id, url, event_types, secret, active, created_at, updated_at
+		FROM webhooks
+		ORDER BY created_at, id
+	`
+
+	rows, err := querier(ctx, r.db).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", translateError(err, "webhook"))
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.EventTypes, &webhook.Secret, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// UpdateWebhook updates an existing webhook, keeping its secret when none is set.
+func (r *webhookRepository) UpdateWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error) {
+	query := `
+		-- name: UpdateWebhook
+		UPDATE webhooks
+		SET url = $1, event_types = $2, secret = COALESCE(NULLIF($3, ''), secret), active = $4, updated_at = $5
+		WHERE id = $6
+		RETURNING id, url, event_types, secret, active, created_at, updated_at
+	`
+
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+
+	var updated Webhook
+	err := querier(ctx, r.db).QueryRow(ctx, query, webhook.URL, webhook.EventTypes, webhook.Secret, webhook.Active, time.Now(), webhook.ID).Scan(
+		&updated.ID, &updated.URL, &updated.EventTypes, &updated.Secret, &updated.Active, &updated.CreatedAt, &updated.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", translateError(err, "webhook"))
+	}
+
+	return &updated, nil
+}
+
+// DeleteWebhook deletes a webhook by ID, along with its delivery log.
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	query := `
+		-- name: DeleteWebhook
+		DELETE FROM webhooks WHERE id = $1
+	`
+
+	result, err := querier(ctx, r.db).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", translateError(err, "webhook"))
+	}
+
+	if result.RowsAffected() == 0 {
+		return newError(ErrNotFound, "webhook not found", nil)
+	}
+
+	return nil
+}
+
+// ListWebhookDeliveries retrieves the latest deliveries of a webhook, the most recent first.
+func (r *webhookRepository) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*WebhookDelivery, error) {
+	query := `
+		-- name: ListWebhookDeliveries
+		SELECT id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			last_attempt_at, response_status, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := querier(ctx, r.db).Query(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", translateError(err, "webhook delivery"))
+	}
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RedeliverWebhookDelivery enqueues a new delivery of the event of a previous one
+// The previous delivery is left untouched, so that the log keeps the outcome of every attempt.
+func (r *webhookRepository) RedeliverWebhookDelivery(ctx context.Context, webhookID, deliveryID uuid.UUID) (*WebhookDelivery, error) {
+	query := `
+		-- name: RedeliverWebhookDelivery
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, created_at, next_attempt_at)
+		SELECT $1, webhook_id, event_id, event_type, payload, $2, $2
+		FROM webhook_deliveries
+		WHERE id = $3 AND webhook_id = $4
+		RETURNING id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			last_attempt_at, response_status, last_error, created_at, delivered_at
+	`
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook delivery ID: %w", err)
+	}
+
+	var d WebhookDelivery
+	err = querier(ctx, r.db).QueryRow(ctx, query, id, time.Now(), deliveryID, webhookID).Scan(
+		&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", translateError(err, "webhook delivery"))
+	}
+
+	return &d, nil
+}
+
+// ClaimWebhookDeliveries implements WebhookRepository
+// Claimed deliveries are leased rather than locked, so that no transaction stays open while partners answer.
+// A dispatcher crashing before recording its attempts only delays the deliveries until the lease ends.
+func (r *webhookRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDispatch, error) {
+	query := `
+		-- name: ClaimWebhookDeliveries
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $2::INTERVAL
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+				AND webhook_id IN (SELECT id FROM webhooks WHERE active)
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.last_attempt_at, d.response_status, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+	`
+
+	rows, err := querier(ctx, r.db).Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", translateError(err, "webhook delivery"))
+	}
+	defer rows.Close()
+
+	var dispatches []*WebhookDispatch
+	for rows.Next() {
+		var d WebhookDispatch
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		dispatches = append(dispatches, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return dispatches, nil
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt in the delivery log.
+func (r *webhookRepository) RecordWebhookAttempt(ctx context.Context, deliveryID uuid.UUID, attempt WebhookAttempt) error {
+	query := `
+		-- name: RecordWebhookAttempt
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_attempt_at = now(), next_attempt_at = now() + $3::INTERVAL,
+			response_status = NULLIF($4, 0), last_error = NULLIF($5, ''),
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() END
+		WHERE id = $1
+	`
+
+	_, err := querier(ctx, r.db).Exec(ctx, query, deliveryID, attempt.Status, attempt.RetryIn, attempt.ResponseStatus, attempt.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", translateError(err, "webhook delivery"))
+	}
+
+	return nil
+}
+
+// Publish implements EventPublisher, it enqueues a delivery of event to every active webhook subscribed to its type
+// Events are published again after failures, so the webhooks already holding a delivery of the event are skipped.
+func (r *webhookRepository) Publish(ctx context.Context, event Event) error {
+	query := `
+		-- name: ListWebhookSubscriptions
+		SELECT id FROM webhooks w
+		WHERE active AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+			AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.webhook_id = w.id AND d.event_id = $1)
+		ORDER BY id
+	`
+
+	rows, err := querier(ctx, r.db).Query(ctx, query, event.ID, event.Type)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	webhookIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return fmt.Errorf("failed to scan webhook subscriptions: %w", err)
+	}
+	if len(webhookIDs) == 0 {
+		return nil
+	}
+
+	// Deliveries are identified by UUIDv7 like every other row
+	ids := make([]uuid.UUID, len(webhookIDs))
+	for i := range ids {
+		if ids[i], err = uuid.NewV7(); err != nil {
+			return fmt.Errorf("failed to generate webhook delivery ID: %w", err)
+		}
+	}
+
+	payload, err := json.Marshal(struct {
+		ID        uuid.UUID       `json:"id"`
+		Type      string          `json:"type"`
+		CreatedAt time.Time       `json:"created_at"`
+		Data      json.RawMessage `json:"data"`
+	}{event.ID, event.Type, event.CreatedAt, event.Payload})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	query = `
+		-- name: EnqueueWebhookDeliveries
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, created_at, next_attempt_at)
+		SELECT d.id, d.webhook_id, $3, $4, $5, $6, $6
+		FROM unnest($1::UUID[], $2::UUID[]) AS d (id, webhook_id)
+	`
+
+	if _, err := querier(ctx, r.db).Exec(ctx, query, ids, webhookIDs, event.ID, event.Type, payload, time.Now()); err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do/v2"
+)
+
+// TestWebhookRepository runs against the database of TEST_DATABASE_URL, sequentially since it shares the outbox table.
+func TestWebhookRepository(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	injector := do.New(Package)
+	do.ProvideValue(injector, &config.Config{Database: config.DatabaseConfig{Driver: DriverPostgres, URL: url}})
+	do.ProvideValue(injector, &logger)
+	t.Cleanup(func() { injector.Shutdown() })
+
+	db := do.MustInvoke[*Database](injector)
+	if _, err := do.MustInvoke[*Migrator](injector).Up(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if _, err := db.Pool().Exec(ctx, `TRUNCATE users, outbox, webhooks CASCADE`); err != nil {
+		t.Fatalf("failed to empty tables: %v", err)
+	}
+
+	repo := do.MustInvoke[WebhookRepository](injector)
+	webhook, err := repo.CreateWebhook(ctx, &Webhook{URL: "https://example.com/hook", EventTypes: []string{EventUserCreated}, Active: true})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	if len(webhook.Secret) == 0 {
+		t.Fatal("CreateWebhook() did not generate a secret")
+	}
+	if _, err := repo.CreateWebhook(ctx, &Webhook{URL: "https://example.com/paused", Active: false}); err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+
+	users := do.MustInvoke[UserRepository](injector)
+	user, err := users.CreateUser(ctx, &User{Name: "John", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := users.DeleteUser(ctx, user.ID, 0); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	// The relay enqueues the deliveries of the events matching an active webhook, once even when the broker fails
+	relay := &OutboxRelay{
+		db:        db,
+		publisher: eventPublishers{repo, &recordingEventPublisher{fail: true}},
+		logger:    &logger,
+		batchSize: 10,
+		backoff:   Backoff{Initial: time.Millisecond, Max: time.Millisecond},
+	}
+	for range 2 {
+		if _, err := relay.relayBatch(ctx); err != nil {
+			t.Fatalf("relayBatch() error = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	dispatches, err := repo.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries() error = %v", err)
+	}
+	if len(dispatches) != 1 || dispatches[0].WebhookID != webhook.ID || dispatches[0].EventType != EventUserCreated {
+		t.Fatalf("ClaimWebhookDeliveries() = %+v, want the user.created delivery of the subscribed webhook", dispatches)
+	}
+	if dispatches[0].URL != webhook.URL || dispatches[0].Secret != webhook.Secret {
+		t.Errorf("dispatch = %+v, want the URL and secret of the webhook", dispatches[0])
+	}
+
+	var payload struct {
+		Type string `json:"type"`
+		Data User   `json:"data"`
+	}
+	if err := json.Unmarshal(dispatches[0].Payload, &payload); err != nil || payload.Type != EventUserCreated || payload.Data.ID != user.ID {
+		t.Errorf("payload = %s, want the event with the user as data", dispatches[0].Payload)
+	}
+
+	if again, err := repo.ClaimWebhookDeliveries(ctx, 10, time.Minute); err != nil || len(again) != 0 {
+		t.Errorf("ClaimWebhookDeliveries() = %d, %v, want leased deliveries to be hidden", len(again), err)
+	}
+
+	attempt := WebhookAttempt{Status: WebhookDeliveryFailed, ResponseStatus: 500, Error: "unexpected response status 500"}
+	if err := repo.RecordWebhookAttempt(ctx, dispatches[0].ID, attempt); err != nil {
+		t.Fatalf("RecordWebhookAttempt() error = %v", err)
+	}
+
+	redelivery, err := repo.RedeliverWebhookDelivery(ctx, webhook.ID, dispatches[0].ID)
+	if err != nil {
+		t.Fatalf("RedeliverWebhookDelivery() error = %v", err)
+	}
+	if redelivery.Status != WebhookDeliveryPending || redelivery.EventID != dispatches[0].EventID || redelivery.Attempts != 0 {
+		t.Errorf("RedeliverWebhookDelivery() = %+v, want a new pending delivery of the event", redelivery)
+	}
+
+	deliveries, err := repo.ListWebhookDeliveries(ctx, webhook.ID, 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries() error = %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].ID != redelivery.ID {
+		t.Fatalf("ListWebhookDeliveries() = %+v, want the redelivery first", deliveries)
+	}
+	if failed := deliveries[1]; failed.Status != WebhookDeliveryFailed || failed.Attempts != 1 || failed.ResponseStatus == nil || *failed.ResponseStatus != 500 {
+		t.Errorf("failed delivery = %+v, want the outcome of its attempt", failed)
+	}
+
+	if err := repo.DeleteWebhook(ctx, webhook.ID); err != nil {
+		t.Fatalf("DeleteWebhook() error = %v", err)
+	}
+	if _, err := repo.RedeliverWebhookDelivery(ctx, webhook.ID, dispatches[0].ID); err == nil {
+		t.Error("RedeliverWebhookDelivery() succeeded, want the deliveries to be deleted along with the webhook")
+	}
+}
@@ -0,0 +1,230 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/config"
+	"github.com/samber/do-template-api/pkg/repositories"
+	"github.com/samber/do/v2"
+)
+
+// Headers of a delivery request.
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+)
+
+const (
+	// retryBackoff is the delay before the first retry of a failed delivery, doubled on every attempt.
+	retryBackoff = 10 * time.Second
+
+	// maxResponseBody bounds the part of a response body which is read, so that the connection can be reused.
+	maxResponseBody = 64 << 10
+
+	// Defaults used when the webhook settings are not configured.
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 20
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 10
+	defaultMaxBackoff   = time.Hour
+)
+
+// Sign returns the signature of a delivery body sent at timestamp, as found in the Webhook-Signature header
+// Receivers recompute it with their secret to authenticate the request, and reject old timestamps to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher sends the pending webhook deliveries
+// Deliveries are claimed for the duration of a request, so that several instances of the API can dispatch them concurrently.
+// Failed deliveries are retried with an exponential backoff, until `webhooks.max_attempts` is reached.
+type Dispatcher struct {
+	repo         repositories.WebhookRepository
+	logger       *zerolog.Logger
+	client       *http.Client
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	backoff      repositories.Backoff
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewDispatcher creates the webhook dispatcher and starts polling in the background
+// This function demonstrates how to initialize a service running a background task.
+func NewDispatcher(injector do.Injector) (*Dispatcher, error) {
+	cfg := do.MustInvoke[*config.Config](injector).Webhooks
+
+	repo, err := do.Invoke[repositories.WebhookRepository](injector)
+	if err != nil {
+		return nil, err
+	}
+
+	dispatcher := &Dispatcher{
+		repo:         repo,
+		logger:       do.MustInvoke[*zerolog.Logger](injector),
+		pollInterval: repositories.DurationOr(time.Duration(cfg.PollInterval)*time.Millisecond, defaultPollInterval),
+		batchSize:    cfg.BatchSize,
+		maxAttempts:  cfg.MaxAttempts,
+		backoff: repositories.Backoff{
+			Initial: retryBackoff,
+			Max:     repositories.DurationOr(time.Duration(cfg.MaxBackoff)*time.Second, defaultMaxBackoff),
+		},
+		client: &http.Client{
+			Timeout:   repositories.DurationOr(time.Duration(cfg.Timeout)*time.Second, defaultTimeout),
+			Transport: newTransport(cfg.AllowPrivateNetworks),
+			// A redirect is an answer of its own, partners must register their final URL
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	if dispatcher.batchSize <= 0 {
+		dispatcher.batchSize = defaultBatchSize
+	}
+	if dispatcher.maxAttempts <= 0 {
+		dispatcher.maxAttempts = defaultMaxAttempts
+	}
+
+	dispatcher.start()
+
+	return dispatcher, nil
+}
+
+// start dispatches the pending deliveries every poll interval.
+func (d *Dispatcher) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		poll := time.NewTimer(0)
+		defer poll.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-poll.C:
+				delay := d.pollInterval
+
+				count, err := d.dispatchBatch(ctx)
+				if err != nil && ctx.Err() == nil {
+					d.logger.Warn().Err(err).Msg("Failed to dispatch webhook deliveries")
+				}
+				// A full batch means that more deliveries are pending
+				if count == d.batchSize {
+					delay = 0
+				}
+
+				poll.Reset(delay)
+			}
+		}
+	}()
+}
+
+// dispatchBatch sends a batch of pending deliveries concurrently, and returns how many were claimed.
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	// The lease outlasts the request, and the recording of its outcome
+	dispatches, err := d.repo.ClaimWebhookDeliveries(ctx, d.batchSize, 2*d.client.Timeout)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, dispatch := range dispatches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			attempt := d.deliver(ctx, dispatch)
+			if ctx.Err() != nil {
+				return
+			}
+			if err := d.repo.RecordWebhookAttempt(ctx, dispatch.ID, attempt); err != nil {
+				d.logger.Warn().Err(err).Str("delivery_id", dispatch.ID.String()).Msg("Failed to record webhook attempt")
+			}
+		}()
+	}
+	wg.Wait()
+
+	return len(dispatches), nil
+}
+
+// deliver sends a delivery, and returns the outcome of the attempt
+// Any 2xx response acknowledges the delivery.
+func (d *Dispatcher) deliver(ctx context.Context, dispatch *repositories.WebhookDispatch) repositories.WebhookAttempt {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.URL, bytes.NewReader(dispatch.Payload))
+	if err != nil {
+		return d.failed(dispatch, 0, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "do-template-api-webhooks")
+	req.Header.Set(HeaderID, dispatch.ID.String())
+	req.Header.Set(HeaderEvent, dispatch.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(dispatch.Secret, timestamp, dispatch.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return d.failed(dispatch, 0, err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return d.failed(dispatch, resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode))
+	}
+
+	return repositories.WebhookAttempt{Status: repositories.WebhookDeliveryDelivered, ResponseStatus: resp.StatusCode}
+}
+
+// failed returns the outcome of a failed attempt, to be retried unless it was the last one.
+func (d *Dispatcher) failed(dispatch *repositories.WebhookDispatch, status int, err error) repositories.WebhookAttempt {
+	attempt := repositories.WebhookAttempt{
+		Status:         repositories.WebhookDeliveryFailed,
+		ResponseStatus: status,
+		Error:          err.Error(),
+	}
+
+	attempts := dispatch.Attempts + 1
+	if attempts < d.maxAttempts {
+		attempt.Status = repositories.WebhookDeliveryPending
+		attempt.RetryIn = d.backoff.Delay(attempts)
+	}
+
+	d.logger.Warn().Err(err).Str("delivery_id", dispatch.ID.String()).Str("webhook_id", dispatch.WebhookID.String()).
+		Int("attempt", attempts).Dur("retry_in", attempt.RetryIn).Msg("Failed to deliver webhook")
+
+	return attempt
+}
+
+// Shutdown stops dispatching, the deliveries in progress are interrupted and sent again once their lease ends.
+func (d *Dispatcher) Shutdown() error {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+	return nil
+}
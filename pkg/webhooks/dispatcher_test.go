@@ -0,0 +1,99 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/samber/do-template-api/pkg/repositories"
+)
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"type":"user.created"}`)
+
+	signature := Sign("whsec_test", 1700000000, body)
+	if signature != Sign("whsec_test", 1700000000, body) {
+		t.Fatal("Sign() is not deterministic")
+	}
+	if len(signature) != len("sha256=")+64 || signature[:7] != "sha256=" {
+		t.Errorf("Sign() = %q, want a hex encoded SHA-256 HMAC prefixed by sha256=", signature)
+	}
+
+	for name, other := range map[string]string{
+		"secret":    Sign("whsec_other", 1700000000, body),
+		"timestamp": Sign("whsec_test", 1700000001, body),
+		"body":      Sign("whsec_test", 1700000000, []byte(`{"type":"user.deleted"}`)),
+	} {
+		if other == signature {
+			t.Errorf("Sign() does not depend on the %s", name)
+		}
+	}
+}
+
+func TestDispatcherDeliver(t *testing.T) {
+	t.Parallel()
+
+	status := http.StatusNoContent
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	logger := zerolog.Nop()
+	dispatcher := &Dispatcher{
+		logger:      &logger,
+		client:      server.Client(),
+		maxAttempts: 3,
+		backoff:     repositories.Backoff{Initial: retryBackoff, Max: time.Minute},
+	}
+
+	dispatch := &repositories.WebhookDispatch{
+		WebhookDelivery: repositories.WebhookDelivery{
+			ID:        uuid.New(),
+			WebhookID: uuid.New(),
+			EventType: repositories.EventUserCreated,
+			Payload:   []byte(`{"type":"user.created"}`),
+		},
+		URL:    server.URL,
+		Secret: "whsec_test",
+	}
+
+	attempt := dispatcher.deliver(t.Context(), dispatch)
+	if attempt.Status != repositories.WebhookDeliveryDelivered || attempt.ResponseStatus != http.StatusNoContent {
+		t.Fatalf("deliver() = %+v, want a delivered attempt", attempt)
+	}
+
+	timestamp, err := strconv.ParseInt(received.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("%s = %q, want a Unix timestamp", HeaderTimestamp, received.Header.Get(HeaderTimestamp))
+	}
+	if got, want := received.Header.Get(HeaderSignature), Sign("whsec_test", timestamp, receivedBody); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+	if received.Header.Get(HeaderID) != dispatch.ID.String() || received.Header.Get(HeaderEvent) != repositories.EventUserCreated {
+		t.Errorf("headers = %v, want the delivery ID and event type", received.Header)
+	}
+
+	status = http.StatusInternalServerError
+	attempt = dispatcher.deliver(t.Context(), dispatch)
+	if attempt.Status != repositories.WebhookDeliveryPending || attempt.ResponseStatus != status || attempt.RetryIn < retryBackoff/2 {
+		t.Errorf("deliver() = %+v, want a pending attempt retried after a backoff", attempt)
+	}
+
+	dispatch.Attempts = 2
+	attempt = dispatcher.deliver(t.Context(), dispatch)
+	if attempt.Status != repositories.WebhookDeliveryFailed || attempt.RetryIn != 0 {
+		t.Errorf("deliver() = %+v, want a failed attempt once the attempts are exhausted", attempt)
+	}
+}
@@ -0,0 +1,10 @@
+package webhooks
+
+import (
+	"github.com/samber/do/v2"
+)
+
+// Package provides the webhook delivery services for dependency injection.
+var Package = do.Package(
+	do.Lazy(NewDispatcher),
+)
@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook URL resolves to an address which is not publicly routable.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// newTransport returns the transport of the deliveries
+// Unless allowPrivate is set, it refuses to connect to loopback, private and link-local addresses, so that webhooks cannot reach internal services.
+// The address is checked once resolved, so that a public host name cannot be pointed at an internal address.
+func newTransport(allowPrivate bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// Connecting through a proxy would bypass the check of the address
+	transport.Proxy = nil
+
+	return transport
+}
+
+// publicOnly is a net.Dialer control function refusing the connections to addresses which are not publicly routable.
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	addr = addr.Unmap()
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublicOnly(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]bool{
+		"93.184.215.14:443":          true,
+		"[2606:2800:21f:cb07::1]:80": true,
+		"127.0.0.1:80":               false,
+		"[::1]:80":                   false,
+		"10.0.0.1:80":                false,
+		"172.16.5.4:80":              false,
+		"192.168.1.1:80":             false,
+		"[fd00::1]:80":               false,
+		"169.254.169.254:80":         false,
+		"[fe80::1]:80":               false,
+		"0.0.0.0:80":                 false,
+		"[::ffff:127.0.0.1]:80":      false,
+	}
+
+	for address, allowed := range testCases {
+		err := publicOnly("tcp", address, nil)
+		if allowed && err != nil {
+			t.Errorf("publicOnly(%q) error = %v, want the address to be allowed", address, err)
+		}
+		if !allowed && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("publicOnly(%q) error = %v, want ErrForbiddenAddress", address, err)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	for allowPrivate, wantErr := range map[bool]bool{false: true, true: false} {
+		transport := newTransport(allowPrivate)
+		client := &http.Client{Transport: transport}
+
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		if wantErr != errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("GET on loopback with allowPrivate = %v, error = %v", allowPrivate, err)
+		}
+		transport.CloseIdleConnections()
+	}
+}